@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// maxBatchOperations is the largest number of operations accepted in a single batch.
+const maxBatchOperations = 100
+
+// batchResult is the outcome of a single operation in a batch. Status is the HTTP status code
+// that the equivalent single request would have been answered with, and Error holds the error
+// message (or validation errors) that it would have been answered with.
+type batchResult struct {
+	Status int         `json:"status"`
+	Movie  *data.Movie `json:"movie,omitempty"`
+	Error  interface{} `json:"error,omitempty"`
+}
+
+// batchMoviesHandler handles the "POST /v1/movies/batch" endpoint. It accepts a list of create,
+// update and delete operations, applies them in a single transaction, and returns a JSON
+// response with a result for each operation in the same order. Updates and deletes are checked
+// against the operation's "version", if one is given.
+//
+// By default the batch is atomic, and if any operation fails then none are applied and a 422
+// Unprocessable Entity response is sent. If "partial" is true then the operations which
+// succeed are applied regardless. Note that creates in a batch skip the duplicate check made by
+// createMovieHandler.
+func (app *application) batchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Partial    bool `json:"partial"`
+		Operations []struct {
+			Action  string      `json:"action"`
+			ID      int64       `json:"id"`
+			Version int32       `json:"version"`
+			Movie   *moviePatch `json:"movie"`
+		} `json:"operations"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Operations) > 0, "operations", "must contain at least one operation")
+	v.Check(len(input.Operations) <= maxBatchOperations, "operations",
+		fmt.Sprintf("must not contain more than %d operations", maxBatchOperations))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	genres, err := app.models.Genres.Index()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Check each operation and build the movie that it writes, in the same way as the single
+	// movie handlers do. Operations which fail here are left out of the batch.
+	results := make([]*batchResult, len(input.Operations))
+	images := make(map[int][]*data.MovieImage)
+
+	var (
+		ops     []*data.BatchOperation
+		indexes []int // The index in the input of each operation in ops.
+		failed  bool
+	)
+
+	for i, in := range input.Operations {
+		op := &data.BatchOperation{Action: in.Action, ID: in.ID, Version: in.Version}
+
+		result, err := app.prepareBatchOperation(op, in.Movie, genres)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if result != nil {
+			results[i] = result
+			failed = true
+			continue
+		}
+
+		// Look up the images of movies being deleted, so that their files can be removed from
+		// storage once the batch has been applied.
+		if op.Action == data.BatchDelete {
+			found, err := app.models.MovieImages.GetAllForMovies(op.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			images[i] = found[op.ID]
+		}
+
+		ops = append(ops, op)
+		indexes = append(indexes, i)
+	}
+
+	// Apply the batch, unless an atomic batch has already failed.
+	var errs []error
+
+	switch {
+	case failed && !input.Partial:
+		errs = make([]error, len(ops))
+		for i := range errs {
+			errs[i] = data.ErrBatchAborted
+		}
+	case len(ops) > 0:
+		errs, err = app.models.Movies.Batch(ops, app.contextGetUser(r).ID, input.Partial)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	var movies []*data.Movie
+
+	for j, op := range ops {
+		i := indexes[j]
+
+		if errs[j] != nil {
+			results[i] = batchErrorResult(errs[j])
+			failed = true
+			continue
+		}
+
+		switch op.Action {
+		case data.BatchCreate:
+			movies = append(movies, op.Movie)
+			results[i] = &batchResult{Status: http.StatusCreated, Movie: op.Movie}
+		case data.BatchUpdate:
+			movies = append(movies, op.Movie)
+			results[i] = &batchResult{Status: http.StatusOK, Movie: op.Movie}
+		case data.BatchDelete:
+			results[i] = &batchResult{Status: http.StatusOK}
+		}
+	}
+
+	app.setRuntimeFormat(w, r, movies...)
+
+	status := http.StatusOK
+	if failed && !input.Partial {
+		status = http.StatusUnprocessableEntity
+	} else {
+		for i := range images {
+			if results[i].Status == http.StatusOK {
+				app.deleteImageFiles(images[i]...)
+			}
+		}
+	}
+
+	err = app.writeJSON(w, status, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// prepareBatchOperation validates a batch operation and sets its Movie to the movie that it
+// writes: a new movie for a create, or the existing movie with the patch applied for an
+// update. It returns a failed result if the operation can't be applied.
+func (app *application) prepareBatchOperation(op *data.BatchOperation, patch *moviePatch, genres data.GenreIndex) (*batchResult, error) {
+	v := validator.New()
+
+	v.Check(validator.In(op.Action, data.BatchActions...), "action", "must be one of create, update or delete")
+	v.Check(op.Action == data.BatchCreate || op.ID > 0, "id", "must be provided")
+	v.Check(op.Action == data.BatchDelete || patch != nil, "movie", "must be provided")
+
+	if !v.Valid() {
+		return &batchResult{Status: http.StatusUnprocessableEntity, Error: v.Errors}, nil
+	}
+
+	switch op.Action {
+	case data.BatchCreate:
+		op.Movie = &data.Movie{}
+	case data.BatchUpdate:
+		movie, err := app.models.Movies.Get(op.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return batchErrorResult(err), nil
+			default:
+				return nil, err
+			}
+		}
+
+		if op.Version != 0 && movie.Version != op.Version {
+			return batchErrorResult(data.ErrEditConflict), nil
+		}
+
+		op.Movie = movie
+	case data.BatchDelete:
+		return nil, nil
+	}
+
+	patch.apply(op.Movie)
+
+	if data.ValidateMovie(v, op.Movie, genres); !v.Valid() {
+		return &batchResult{Status: http.StatusUnprocessableEntity, Error: v.Errors}, nil
+	}
+
+	return nil, nil
+}
+
+// batchErrorResult returns the result for a batch operation which failed with one of the
+// errors returned by MovieModel.Batch.
+func batchErrorResult(err error) *batchResult {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return &batchResult{Status: http.StatusNotFound, Error: "the requested resource could not be found"}
+	case errors.Is(err, data.ErrEditConflict):
+		return &batchResult{Status: http.StatusConflict, Error: "unable to update the record due to an edit conflict, please try again"}
+	case errors.Is(err, data.ErrDuplicateIMDbID):
+		return &batchResult{Status: http.StatusUnprocessableEntity, Error: map[string]string{"imdb_id": "a movie with this IMDb ID already exists"}}
+	case errors.Is(err, data.ErrDuplicateTMDBID):
+		return &batchResult{Status: http.StatusUnprocessableEntity, Error: map[string]string{"tmdb_id": "a movie with this TMDB ID already exists"}}
+	default:
+		return &batchResult{Status: http.StatusFailedDependency, Error: err.Error()}
+	}
+}
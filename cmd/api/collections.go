@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// createCollectionHandler handles the "POST /v1/collections" endpoint and returns a JSON response
+// of the newly created collection. The movie_ids in the request body give the collection's movies
+// in order.
+func (app *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		MovieIDs    []int64 `json:"movie_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection := &data.Collection{
+		Name:        input.Name,
+		Description: input.Description,
+	}
+
+	v := validator.New()
+
+	if data.ValidateCollection(v, collection, input.MovieIDs); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.Insert(collection, input.MovieIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrCollectionMovieNotFound):
+			v.AddError("movie_ids", "must only contain the IDs of existing movies")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/collections/%d", collection.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCollectionHandler handles the "GET /v1/collections/:id" endpoint and returns a JSON
+// response of the requested collection, with its movies in order.
+func (app *application) showCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	collection, err := app.models.Collections.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateCollectionHandler handles the "PATCH /v1/collections/:id" endpoint and returns a JSON
+// response of the updated collection. If movie_ids is in the request body, then it replaces the
+// collection's movies and their order.
+func (app *application) updateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	collection, err := app.models.Collections.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if r.Header.Get("X-Expected-Version") != "" {
+		if strconv.FormatInt(int64(collection.Version), 10) != r.Header.Get("X-Expected-Version") {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
+	var input struct {
+		Name        *string  `json:"name"`
+		Description *string  `json:"description"`
+		MovieIDs    *[]int64 `json:"movie_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		collection.Name = *input.Name
+	}
+
+	if input.Description != nil {
+		collection.Description = *input.Description
+	}
+
+	// A nil slice leaves the movies unchanged, so an empty list of movie IDs must be non-nil.
+	var movieIDs []int64
+	if input.MovieIDs != nil {
+		movieIDs = append([]int64{}, *input.MovieIDs...)
+	}
+
+	v := validator.New()
+
+	if data.ValidateCollection(v, collection, movieIDs); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.Update(collection, movieIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrCollectionMovieNotFound):
+			v.AddError("movie_ids", "must only contain the IDs of existing movies")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCollectionHandler handles the "DELETE /v1/collections/:id" endpoint. The movies in the
+// collection aren't deleted.
+func (app *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Collections.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "collection successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listCollectionsHandler handles the "GET /v1/collections" endpoint and returns a paginated JSON
+// response of collections, optionally filtered by name. The movies in a collection are available
+// from "GET /v1/collections/:id", or from "GET /v1/movies?collection=:id".
+func (app *application) listCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readStrings(qs, "name", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readStrings(qs, "sort", "name")
+	input.Filters.SortSafeList = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	collections, metadata, err := app.models.Collections.GetAll(input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"collections": collections, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// loadCollections embeds the collections for each of the provided movies, using a single query
+// for all of them.
+func (app *application) loadCollections(movies ...*data.Movie) error {
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	collections, err := app.models.Collections.GetAllForMovies(ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Collections = collections[movie.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+)
+
+// movieETag returns the entity tag of a movie, which is derived from its version number. It is
+// a strong entity tag, so that it can be used with If-Match.
+func movieETag(movie *data.Movie) string {
+	return fmt.Sprintf(`"%d"`, movie.Version)
+}
+
+// movieRepresentationETag returns the entity tag of a movie's response body, which is its
+// version followed by a hash of the body, e.g. "3-1f2e3d4c5b6a7988". The body includes data that
+// changes without the version being bumped, such as the ratings, credits and images, and differs
+// with the runtime format and language that the client asks for, so If-None-Match is checked
+// against this tag rather than the version alone. The version prefix means the tag can still be
+// used with If-Match.
+func movieRepresentationETag(movie *data.Movie, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%x"`, movie.Version, sum[:8])
+}
+
+// movieETagMatches reports whether an If-Match header value matches a movie. Only the version
+// part of each entity tag is compared, so that tags from both movieETag and
+// movieRepresentationETag match for as long as the movie's version is unchanged. Weak entity
+// tags never match.
+func movieETagMatches(header string, movie *data.Movie) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if len(candidate) < 2 || !strings.HasPrefix(candidate, `"`) || !strings.HasSuffix(candidate, `"`) {
+			continue
+		}
+
+		version, _, _ := strings.Cut(candidate[1:len(candidate)-1], "-")
+		if version == strconv.FormatInt(int64(movie.Version), 10) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// etagMatches reports whether an entity tag matches any of those listed in an If-Match or
+// If-None-Match header value, or the header value is "*". Weak comparison (used for
+// If-None-Match) ignores the W/ prefix of weak entity tags, while strong comparison (used for
+// If-Match) never matches them.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified sets the ETag of the response for a movie from the response body, and checks the
+// If-None-Match header of the request against it. If it matches then it sends a 304 Not
+// Modified response and returns true.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, movie *data.Movie, env envelope) (bool, error) {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return false, err
+	}
+
+	etag := movieRepresentationETag(movie, body)
+	w.Header().Set("ETag", etag)
+
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// checkPreconditions checks the preconditions of a request which changes a movie, and if they
+// aren't met then sends a response and returns false. If-Match is checked against the movie's
+// version (see movieETagMatches), with a 412 Precondition Failed response if it doesn't match. The older
+// X-Expected-Version header is still supported, with a 409 Conflict response if it doesn't
+// match. In strict mode, requests with neither header get a 428 Precondition Required response.
+func (app *application) checkPreconditions(w http.ResponseWriter, r *http.Request, movie *data.Movie) bool {
+	ifMatch := r.Header.Get("If-Match")
+	expectedVersion := r.Header.Get("X-Expected-Version")
+
+	switch {
+	case ifMatch != "":
+		if !movieETagMatches(ifMatch, movie) {
+			app.preconditionFailedResponse(w, r)
+			return false
+		}
+	case expectedVersion != "":
+		if strconv.FormatInt(int64(movie.Version), 10) != expectedVersion {
+			app.editConflictResponse(w, r)
+			return false
+		}
+	case app.config.preconditions.required:
+		app.preconditionRequiredResponse(w, r)
+		return false
+	}
+
+	return true
+}
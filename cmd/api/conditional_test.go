@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+)
+
+// TestETagMatches tests the comparison of entity tags with If-Match and If-None-Match header
+// values.
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		weak   bool
+		want   bool
+	}{
+		{"Match", `"3"`, false, true},
+		{"No match", `"2"`, false, false},
+		{"Any", `*`, false, true},
+		{"List", `"1", "3"`, false, true},
+		{"Weak tag with strong comparison", `W/"3"`, false, false},
+		{"Weak tag with weak comparison", `W/"3"`, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := etagMatches(tt.header, `"3"`, tt.weak)
+			if got != tt.want {
+				t.Errorf("etagMatches(%q) = %t; want %t", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMovieETagMatches tests the comparison of If-Match header values with a movie's version.
+func TestMovieETagMatches(t *testing.T) {
+	movie := &data.Movie{Version: 3}
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"Version tag", `"3"`, true},
+		{"Representation tag", movieRepresentationETag(movie, []byte(`{}`)), true},
+		{"Other version", `"2-1f2e3d4c5b6a7988"`, false},
+		{"Any", `*`, true},
+		{"List", `"1", "3"`, true},
+		{"Weak tag", `W/"3"`, false},
+		{"Unquoted", `3`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := movieETagMatches(tt.header, movie)
+			if got != tt.want {
+				t.Errorf("movieETagMatches(%q) = %t; want %t", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMovieRepresentationETag checks that the ETag of a movie's response body changes with the
+// body, even when the movie's version doesn't.
+func TestMovieRepresentationETag(t *testing.T) {
+	movie := &data.Movie{Version: 3}
+
+	rated := movieRepresentationETag(movie, []byte(`{"movie":{"average_rating":4.5}}`))
+	rerated := movieRepresentationETag(movie, []byte(`{"movie":{"average_rating":4}}`))
+
+	if rated == rerated {
+		t.Errorf("got the same ETag %s for different bodies", rated)
+	}
+
+	if !strings.HasPrefix(rated, `"3-`) {
+		t.Errorf("got ETag %s; want it to start with the version", rated)
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/jsonpatch"
 )
 
 // logError method is a generic helper for logging an error message in *application, as well
@@ -76,6 +80,49 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+// duplicateMovieResponse sends a JSON-formatted error message to the client with a 409 Conflict
+// status code, listing the existing movies which the new movie appears to duplicate.
+func (app *application) duplicateMovieResponse(w http.ResponseWriter, r *http.Request, duplicates []*data.DuplicateCandidate) {
+	env := envelope{
+		"error":      "a movie with this title and year already exists, use ?force=true to create it anyway",
+		"duplicates": duplicates,
+	}
+
+	err := app.writeJSON(w, http.StatusConflict, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+// patchFailedResponse sends the response for a patch document which couldn't be applied to the
+// movie: 409 Conflict if a test operation failed, 422 Unprocessable Entity if the patch refers
+// to something that doesn't exist, and 400 Bad Request for anything else.
+func (app *application) patchFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		app.errorResponse(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, jsonpatch.ErrPathNotFound):
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+	default:
+		app.badRequestResponse(w, r, err)
+	}
+}
+
+// preconditionFailedResponse sends a JSON-formatted error message with a 412 Precondition Failed
+// status code, when the If-Match header of a request doesn't match the current resource.
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been changed since it was fetched, fetch it again and retry"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// preconditionRequiredResponse sends a JSON-formatted error message with a 428 Precondition
+// Required status code, when a write is made without an If-Match header in strict mode.
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this request must be conditional, use an If-Match header with the record's ETag"
+	app.errorResponse(w, r, http.StatusPreconditionRequired, message)
+}
+
 // rateLimitExceedResponse sends a JSON-formatted error message with a 429 Too Many Requests
 // status code to the client.
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
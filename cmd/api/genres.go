@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+)
+
+// listGenresHandler handles the "GET /v1/genres" endpoint and returns a JSON response of every
+// canonical genre, along with its aliases and the number of movies in the genre.
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
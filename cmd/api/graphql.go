@@ -0,0 +1,628 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/graphql"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// graphqlHandler handles the "POST /v1/graphql" endpoint, which executes a GraphQL query or
+// mutation over the movies, genres and the current user. Permissions are checked by each field
+// rather than for the whole endpoint, so that anonymous clients get an error for the fields they
+// can't see instead of a 401 Unauthorized response. Requests which can't be executed, because
+// they are invalid or exceed the depth and cost limits, get a 400 Bad Request response.
+func (app *application) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Query != "", "query", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	resp, err := graphql.Execute(r.Context(), app.graphqlSchema(r), graphql.Params{
+		Query:         input.Query,
+		OperationName: input.OperationName,
+		Variables:     input.Variables,
+		MaxDepth:      app.config.graphql.maxDepth,
+		MaxCost:       app.config.graphql.maxCost,
+		LogError:      func(err error) { app.logError(r, err) },
+	})
+	if err != nil {
+		env := envelope{"errors": []envelope{{"message": err.Error()}}}
+
+		err = app.writeJSON(w, http.StatusBadRequest, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"data": resp.Data}
+	if len(resp.Errors) > 0 {
+		env["errors"] = resp.Errors
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// graphqlRequest holds the state of a GraphQL request which is shared between its resolvers.
+type graphqlRequest struct {
+	app  *application
+	user *data.User
+	// permissions caches the result of checking each permission, so that a query which selects
+	// several fields needing the same permission only looks it up once.
+	permissions map[string]error
+}
+
+// require checks that the user has a permission, in the same way as the requirePermissions
+// middleware, and returns a GraphQL error for the field if they don't.
+func (g *graphqlRequest) require(code string) error {
+	if err, ok := g.permissions[code]; ok {
+		return err
+	}
+
+	err := g.app.checkPermission(g.user, code)
+
+	switch {
+	case errors.Is(err, errAuthenticationRequired):
+		err = graphql.Errorf("UNAUTHENTICATED", "you must be authenticated to access this resource")
+	case errors.Is(err, errInactiveAccount):
+		err = graphql.Errorf("FORBIDDEN", "your user account must be activated to access this resource")
+	case errors.Is(err, errNotPermitted):
+		err = graphql.Errorf("FORBIDDEN", "your user account doesn't have the necessary permissions to access this resource")
+	}
+
+	g.permissions[code] = err
+
+	return err
+}
+
+// graphqlValidationError returns the GraphQL error for a failed validation, with the errors for
+// each field in its extensions.
+func graphqlValidationError(fields map[string]string) *graphql.Error {
+	return &graphql.Error{
+		Message:    "failed validation",
+		Extensions: map[string]interface{}{"code": "VALIDATION_FAILED", "fields": fields},
+	}
+}
+
+// graphqlEditConflictError is returned when a mutation's version doesn't match the movie, or the
+// movie changes while it is being updated.
+var graphqlEditConflictError = graphql.Errorf("EDIT_CONFLICT", "unable to update the record due to an edit conflict, please try again")
+
+// graphqlNotFoundError is returned when a movie doesn't exist.
+var graphqlNotFoundError = graphql.Errorf("NOT_FOUND", "the requested resource could not be found")
+
+// graphqlSchema returns the GraphQL schema for a request. Field and argument names match the
+// JSON of the REST endpoints, so the input of the movie mutations is read as a moviePatch.
+func (app *application) graphqlSchema(r *http.Request) *graphql.Schema {
+	g := &graphqlRequest{app: app, user: app.contextGetUser(r), permissions: make(map[string]error)}
+
+	credit := &graphql.Object{Name: "Credit", Fields: map[string]*graphql.Field{
+		"id":            creditField(func(c *data.Credit) interface{} { return c.ID }),
+		"person_id":     creditField(func(c *data.Credit) interface{} { return c.PersonID }),
+		"person_name":   creditField(func(c *data.Credit) interface{} { return c.PersonName }),
+		"role":          creditField(func(c *data.Credit) interface{} { return c.Role }),
+		"character":     creditField(func(c *data.Credit) interface{} { return c.Character }),
+		"billing_order": creditField(func(c *data.Credit) interface{} { return c.BillingOrder }),
+	}}
+
+	collection := &graphql.Object{Name: "MovieCollection", Fields: map[string]*graphql.Field{
+		"id":       collectionField(func(c *data.MovieCollection) interface{} { return c.ID }),
+		"name":     collectionField(func(c *data.MovieCollection) interface{} { return c.Name }),
+		"position": collectionField(func(c *data.MovieCollection) interface{} { return c.Position }),
+	}}
+
+	// The credits and collections of a movie are batch loaded by the field which returned the
+	// movie (see loadSelected), so their resolvers just return them.
+	movie := &graphql.Object{Name: "Movie", Fields: map[string]*graphql.Field{
+		"id":             movieField(func(m *data.Movie) interface{} { return m.ID }),
+		"title":          movieField(func(m *data.Movie) interface{} { return m.Title }),
+		"year":           movieField(func(m *data.Movie) interface{} { return m.Year }),
+		"runtime":        movieField(func(m *data.Movie) interface{} { return int32(m.Runtime) }),
+		"genres":         movieField(func(m *data.Movie) interface{} { return m.Genres }),
+		"synopsis":       movieField(func(m *data.Movie) interface{} { return m.Synopsis }),
+		"original_title": movieField(func(m *data.Movie) interface{} { return m.OriginalTitle }),
+		"languages":      movieField(func(m *data.Movie) interface{} { return m.Languages }),
+		"certifications": movieField(func(m *data.Movie) interface{} { return m.Certifications }),
+		"release_status": movieField(func(m *data.Movie) interface{} { return m.ReleaseStatus }),
+		"release_dates":  movieField(func(m *data.Movie) interface{} { return m.ReleaseDates }),
+		"imdb_id":        movieField(func(m *data.Movie) interface{} { return m.IMDbID }),
+		"tmdb_id":        movieField(func(m *data.Movie) interface{} { return m.TMDBID }),
+		"average_rating": movieField(func(m *data.Movie) interface{} { return m.AverageRating }),
+		"rating_count":   movieField(func(m *data.Movie) interface{} { return m.RatingCount }),
+		"version":        movieField(func(m *data.Movie) interface{} { return m.Version }),
+		"credits": {Type: credit, ListSize: 10, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*data.Movie).Credits, nil
+		}},
+		"collections": {Type: collection, ListSize: 5, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*data.Movie).Collections, nil
+		}},
+	}}
+
+	metadata := &graphql.Object{Name: "Metadata", Fields: map[string]*graphql.Field{
+		"current_page":  metadataField(func(m data.Metadata) interface{} { return m.CurrentPage }),
+		"page_size":     metadataField(func(m data.Metadata) interface{} { return m.PageSize }),
+		"first_page":    metadataField(func(m data.Metadata) interface{} { return m.FirstPage }),
+		"last_page":     metadataField(func(m data.Metadata) interface{} { return m.LastPage }),
+		"total_records": metadataField(func(m data.Metadata) interface{} { return m.TotalRecords }),
+	}}
+
+	moviePage := &graphql.Object{Name: "MoviePage", Fields: map[string]*graphql.Field{
+		"movies": {Type: movie, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*graphqlMoviePage).movies, nil
+		}},
+		"metadata": {Type: metadata, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*graphqlMoviePage).metadata, nil
+		}},
+	}}
+
+	genre := &graphql.Object{Name: "Genre", Fields: map[string]*graphql.Field{
+		"id":          genreField(func(g *data.Genre) interface{} { return g.ID }),
+		"name":        genreField(func(g *data.Genre) interface{} { return g.Name }),
+		"aliases":     genreField(func(g *data.Genre) interface{} { return g.Aliases }),
+		"movie_count": genreField(func(g *data.Genre) interface{} { return g.MovieCount }),
+	}}
+
+	user := &graphql.Object{Name: "User", Fields: map[string]*graphql.Field{
+		"id":        userField(func(u *data.User) interface{} { return u.ID }),
+		"name":      userField(func(u *data.User) interface{} { return u.Name }),
+		"email":     userField(func(u *data.User) interface{} { return u.Email }),
+		"activated": userField(func(u *data.User) interface{} { return u.Activated }),
+		"permissions": {Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return app.models.Permissions.GetAllForUser(p.Source.(*data.User).ID)
+		}},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"movie": {Type: movie, Args: []string{"id"}, Resolve: g.resolveMovie},
+		"movies": {
+			Type:     moviePage,
+			Args:     []string{"search", "genres", "release_status", "page", "page_size", "sort"},
+			ListSize: 20,
+			SizeArg:  "page_size",
+			Resolve:  g.resolveMovies,
+		},
+		"genres": {Type: genre, ListSize: 50, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if err := g.require("movies:read"); err != nil {
+				return nil, err
+			}
+			return app.models.Genres.GetAll()
+		}},
+		"me": {Type: user, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if g.user.IsAnonymous() {
+				return nil, graphql.Errorf("UNAUTHENTICATED", "you must be authenticated to access this resource")
+			}
+			return g.user, nil
+		}},
+	}}
+
+	mutation := &graphql.Object{Name: "Mutation", Fields: map[string]*graphql.Field{
+		"create_movie": {Type: movie, Args: []string{"input", "force"}, Resolve: g.resolveCreateMovie},
+		"update_movie": {Type: movie, Args: []string{"id", "version", "input"}, Resolve: g.resolveUpdateMovie},
+		"delete_movie": {Args: []string{"id", "version"}, Resolve: g.resolveDeleteMovie},
+	}}
+
+	return &graphql.Schema{Query: query, Mutation: mutation}
+}
+
+// graphqlMoviePage is the value of the MoviePage type returned by the movies query.
+type graphqlMoviePage struct {
+	movies   []*data.Movie
+	metadata data.Metadata
+}
+
+func (g *graphqlRequest) resolveMovie(p graphql.ResolveParams) (interface{}, error) {
+	if err := g.require("movies:read"); err != nil {
+		return nil, err
+	}
+
+	movie, err := g.getMovie(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.loadSelected(p.Node, nil, movie)
+	if err != nil {
+		return nil, err
+	}
+
+	return movie, nil
+}
+
+// resolveMovies lists movies with a subset of the filters of the "GET /v1/movies" endpoint,
+// validated in the same way.
+func (g *graphqlRequest) resolveMovies(p graphql.ResolveParams) (interface{}, error) {
+	if err := g.require("movies:read"); err != nil {
+		return nil, err
+	}
+
+	mf := data.NewMovieFilters()
+	var filters data.Filters
+
+	search, err := p.String("search", "")
+	if err != nil {
+		return nil, err
+	}
+	mf.Search = data.ParseSearchQuery(search)
+
+	genres, err := p.Strings("genres")
+	if err != nil {
+		return nil, err
+	}
+	if genres != nil {
+		mf.Genres = genres
+	}
+
+	mf.ReleaseStatus, err = p.String("release_status", "")
+	if err != nil {
+		return nil, err
+	}
+
+	filters.Page, err = p.Int("page", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	filters.PageSize, err = p.Int("page_size", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultSort := "id"
+	if mf.Search != "" {
+		defaultSort = "-relevance"
+	}
+
+	filters.Sort, err = p.String("sort", defaultSort)
+	if err != nil {
+		return nil, err
+	}
+
+	filters.SortSafeList = []string{
+		"id", "title", "year", "runtime", "average_rating", "rating_count", "relevance",
+		"-id", "-title", "-year", "-runtime", "-average_rating", "-rating_count", "-relevance",
+	}
+
+	// Map the genres filter onto canonical genre names, as the REST endpoint does.
+	if len(mf.Genres) > 0 {
+		genres, err := g.app.models.Genres.Index()
+		if err != nil {
+			return nil, err
+		}
+
+		for i, genre := range mf.Genres {
+			if canonical, ok := genres.Canonical(genre); ok {
+				mf.Genres[i] = canonical
+			}
+		}
+	}
+
+	v := validator.New()
+
+	data.ValidateMovieFilters(v, mf)
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, graphqlValidationError(v.Errors)
+	}
+
+	movies, metadata, err := g.app.models.Movies.GetAll(mf, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.loadSelected(p.Node, []string{"movies"}, movies...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &graphqlMoviePage{movies: movies, metadata: metadata}, nil
+}
+
+func (g *graphqlRequest) resolveCreateMovie(p graphql.ResolveParams) (interface{}, error) {
+	if err := g.require("movies:write"); err != nil {
+		return nil, err
+	}
+
+	input, err := readMovieInput(p)
+	if err != nil {
+		return nil, err
+	}
+
+	force, err := p.Bool("force", false)
+	if err != nil {
+		return nil, err
+	}
+
+	movie := &data.Movie{}
+	input.apply(movie)
+
+	err = g.validateMovie(movie)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to create an exact duplicate of an existing movie unless forced, as the REST
+	// endpoint does.
+	if !force {
+		duplicates, err := g.app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, duplicate := range duplicates {
+			if duplicate.Exact {
+				return nil, &graphql.Error{
+					Message:    "a movie with this title and year already exists, use force: true to create it anyway",
+					Extensions: map[string]interface{}{"code": "DUPLICATE", "duplicates": duplicates},
+				}
+			}
+		}
+	}
+
+	err = g.app.models.Movies.Insert(movie, g.user.ID)
+	if err != nil {
+		return nil, movieWriteError(err)
+	}
+
+	return movie, g.loadSelected(p.Node, nil, movie)
+}
+
+func (g *graphqlRequest) resolveUpdateMovie(p graphql.ResolveParams) (interface{}, error) {
+	if err := g.require("movies:write"); err != nil {
+		return nil, err
+	}
+
+	movie, err := g.getMovie(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.checkVersion(p, movie)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := readMovieInput(p)
+	if err != nil {
+		return nil, err
+	}
+
+	input.apply(movie)
+
+	err = g.validateMovie(movie)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.app.models.Movies.Update(movie, g.user.ID)
+	if err != nil {
+		return nil, movieWriteError(err)
+	}
+
+	return movie, g.loadSelected(p.Node, nil, movie)
+}
+
+// resolveDeleteMovie deletes a movie and its images, and returns the ID of the deleted movie.
+func (g *graphqlRequest) resolveDeleteMovie(p graphql.ResolveParams) (interface{}, error) {
+	if err := g.require("movies:write"); err != nil {
+		return nil, err
+	}
+
+	movie, err := g.getMovie(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.checkVersion(p, movie)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := g.app.models.MovieImages.GetAllForMovies(movie.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.app.models.Movies.Delete(movie.ID, movie.Version, g.user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFoundError
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphqlEditConflictError
+		default:
+			return nil, err
+		}
+	}
+
+	g.app.deleteImageFiles(images[movie.ID]...)
+
+	return movie.ID, nil
+}
+
+// getMovie fetches the movie with the ID in the field's id argument.
+func (g *graphqlRequest) getMovie(p graphql.ResolveParams) (*data.Movie, error) {
+	id, err := p.Int("id", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if id < 1 {
+		return nil, graphqlNotFoundError
+	}
+
+	movie, err := g.app.models.Movies.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFoundError
+		default:
+			return nil, err
+		}
+	}
+
+	return movie, nil
+}
+
+// checkVersion checks the field's version argument against the movie, like the
+// X-Expected-Version header of the REST endpoints. In strict mode the version is required.
+func (g *graphqlRequest) checkVersion(p graphql.ResolveParams, movie *data.Movie) error {
+	version, err := p.Int("version", 0)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version != 0:
+		if int32(version) != movie.Version {
+			return graphqlEditConflictError
+		}
+	case g.app.config.preconditions.required:
+		return graphql.Errorf("PRECONDITION_REQUIRED", "this mutation must be conditional, provide the movie's version")
+	}
+
+	return nil
+}
+
+// validateMovie validates a movie with data.ValidateMovie, which also normalises its genres.
+func (g *graphqlRequest) validateMovie(movie *data.Movie) error {
+	genres, err := g.app.models.Genres.Index()
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		return graphqlValidationError(v.Errors)
+	}
+
+	return nil
+}
+
+// loadSelected batch loads the credits and collections of the movies returned by a field, if
+// they are selected below the field at the given path, so that each is fetched with a single
+// query for all of the movies rather than one query per movie.
+func (g *graphqlRequest) loadSelected(node *graphql.Node, path []string, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	if node.Selects(append(path, "credits")...) {
+		err := g.app.loadCredits(movies...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if node.Selects(append(path, "collections")...) {
+		return g.app.loadCollections(movies...)
+	}
+
+	return nil
+}
+
+// readMovieInput reads the input argument of a movie mutation. Unknown fields are rejected, like
+// they are in a request body.
+func readMovieInput(p graphql.ResolveParams) (moviePatch, error) {
+	var input moviePatch
+
+	js, err := p.Object("input")
+	if err != nil {
+		return input, err
+	}
+
+	if js == nil {
+		return input, graphql.Errorf("BAD_USER_INPUT", "argument \"input\" must be provided")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&input)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+
+		switch {
+		case errors.As(err, &unmarshalTypeError):
+			return input, graphql.Errorf("BAD_USER_INPUT", "input contains the incorrect type for field %q", unmarshalTypeError.Field)
+		default:
+			return input, graphql.Errorf("BAD_USER_INPUT", "input is invalid: %s", err)
+		}
+	}
+
+	return input, nil
+}
+
+// movieWriteError returns the GraphQL error for an error from inserting or updating a movie.
+func movieWriteError(err error) error {
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		return graphqlEditConflictError
+	case errors.Is(err, data.ErrDuplicateIMDbID):
+		return graphqlValidationError(map[string]string{"imdb_id": "a movie with this IMDb ID already exists"})
+	case errors.Is(err, data.ErrDuplicateTMDBID):
+		return graphqlValidationError(map[string]string{"tmdb_id": "a movie with this TMDB ID already exists"})
+	default:
+		return err
+	}
+}
+
+// movieField, creditField, collectionField, metadataField, genreField and userField return
+// scalar fields whose values are read from the parent object by fn.
+func movieField(fn func(*data.Movie) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.Movie)), nil
+	}}
+}
+
+func creditField(fn func(*data.Credit) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.Credit)), nil
+	}}
+}
+
+func collectionField(fn func(*data.MovieCollection) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.MovieCollection)), nil
+	}}
+}
+
+func metadataField(fn func(data.Metadata) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(data.Metadata)), nil
+	}}
+}
+
+func genreField(fn func(*data.Genre) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.Genre)), nil
+	}}
+}
+
+func userField(fn func(*data.User) interface{}) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(*data.User)), nil
+	}}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+)
+
+func TestGraphqlHandler(t *testing.T) {
+	app := newTestApp()
+	app.config.graphql.maxDepth = 8
+	app.config.graphql.maxCost = 1000
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []string
+	}{
+		{"Anonymous user", `{"query": "{ me { id } movies { movies { title } } }"}`, http.StatusOK, []string{
+			`"data":{"me":null,"movies":null}`,
+			`"message":"you must be authenticated to access this resource","path":["me"],"extensions":{"code":"UNAUTHENTICATED"}`,
+			`"path":["movies"],"extensions":{"code":"UNAUTHENTICATED"}`,
+		}},
+		{"Invalid query", `{"query": "{ movies { movies { budget } } }"}`, http.StatusBadRequest, []string{
+			`"message":"cannot query field \"budget\" on type Movie"`,
+		}},
+		{"Too costly", `{"query": "{ movies(page_size: 100) { movies { credits { id role character } } } }"}`, http.StatusBadRequest, []string{
+			`"message":"the query has a cost of 3201, which is more than the maximum of 1000"`,
+		}},
+		{"Missing query", `{"variables": {}}`, http.StatusUnprocessableEntity, []string{
+			`"query":"must be provided"`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(tt.body))
+			r = app.contextSetUser(r, data.AnonymousUser)
+
+			app.graphqlHandler(rr, r)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rr.Code)
+			}
+
+			// Compact the indented response body, to compare it with the wanted snippets.
+			var body bytes.Buffer
+
+			err := json.Compact(&body, rr.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body.String(), want) {
+					t.Errorf("want body to contain %q; got %q", want, body.String())
+				}
+			}
+		})
+	}
+}
+
+// TestGraphqlMovies checks that the movies query returns movies when it isn't filtered.
+func TestGraphqlMovies(t *testing.T) {
+	app := newTestApp()
+	app.config.graphql.maxDepth = 8
+	app.config.graphql.maxCost = 1000
+	app.models = data.NewModels(newTestDB(t))
+	app.models.Permissions = data.PermissionModel{DB: permissionsDB(map[int64][]string{
+		1: {"movies:read"},
+	})}
+
+	movie := &data.Movie{
+		Title:         "Alien",
+		Year:          1979,
+		Runtime:       117,
+		Genres:        []string{"horror"},
+		Languages:     []string{"en"},
+		ReleaseStatus: data.ReleaseReleased,
+	}
+
+	err := app.models.Movies.Insert(movie, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := app.models.Movies.Delete(movie.ID, movie.Version, 0); err != nil {
+			t.Error(err)
+		}
+	})
+
+	rr := httptest.NewRecorder()
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/graphql",
+		strings.NewReader(`{"query": "{ movies(page_size: 1, sort: \"-id\") { movies { id title } } }"}`))
+	r = app.contextSetUser(r, &data.User{ID: 1, Activated: true})
+
+	app.graphqlHandler(rr, r)
+
+	var body bytes.Buffer
+
+	err = json.Compact(&body, rr.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf(`"movies":[{"id":%d,"title":"Alien"}]`, movie.ID)
+	if !strings.Contains(body.String(), want) {
+		t.Errorf("want body to contain %q; got %q", want, body.String())
+	}
+}
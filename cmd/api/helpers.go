@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
 	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -20,16 +24,66 @@ type envelope map[string]interface{}
 // readIDParam reads interpolated "id" from request URL and returns it and nil. If there is an error
 // it returns and 0 and an error.
 func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+// readNamedIDParam reads an interpolated ID parameter with the given name from the request URL,
+// for routes which contain more than one ID. If there is an error it returns 0 and an error.
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
 }
 
+// dispatchParam returns a handler which routes requests on the value of the named URL
+// parameter. httprouter doesn't allow a static path segment alongside a parameter in the same
+// position, so a route such as "GET /v1/movies/autocomplete" is registered as
+// "GET /v1/movies/:id" and dispatched here, with any other value going to the fallback handler.
+func (app *application) dispatchParam(name string, routes map[string]http.HandlerFunc,
+	fallback http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		if handler, ok := routes[params.ByName(name)]; ok {
+			handler(w, r)
+			return
+		}
+
+		fallback(w, r)
+	}
+}
+
+// methodNotAllowed returns a handler which sends the same 405 Method Not Allowed response as the
+// router does, with an Allow header listing the other methods registered for the request path.
+// It's the fallback for routes, such as POST /v1/movies/batch, that share a path with a parameter
+// but only exist for some of its values.
+func (app *application) methodNotAllowed(router *httprouter.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := []string{http.MethodOptions}
+
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete} {
+			if method == r.Method {
+				continue
+			}
+
+			if handle, _, _ := router.Lookup(method, r.URL.Path); handle != nil {
+				allowed = append(allowed, method)
+			}
+		}
+
+		sort.Strings(allowed)
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		app.methodNotAllowedResponse(w, r)
+	}
+}
+
 // writeJSON marshals data structure to encoded JSON response. It returns an error if there are
 // any issues, else error is nil.
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope,
@@ -160,6 +214,77 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 	return nil
 }
 
+// selectFields returns the JSON encoding of v as a map of its top-level fields, keeping only
+// the named fields. It's used to serialise sparse fieldsets.
+func selectFields(v interface{}, fields []string) (map[string]json.RawMessage, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+
+	err = json.Unmarshal(js, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+
+	return selected, nil
+}
+
+// wantField reports whether the named field is in a sparse fieldset. Every field is wanted when
+// there is no sparse fieldset.
+func wantField(fields []string, name string) bool {
+	if fields == nil {
+		return true
+	}
+
+	for _, field := range fields {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setRuntimeFormat sets the format of the movies' runtimes in the response to the one requested
+// by the client, either with the runtime_format query string parameter or with a runtime
+// parameter in the Accept header (such as "application/json; runtime=iso8601"). Unknown formats
+// are ignored, so the movies keep the default "<runtime> mins" format.
+func (app *application) setRuntimeFormat(w http.ResponseWriter, r *http.Request, movies ...*data.Movie) {
+	// The Accept header can change the response, so caches must take it into account.
+	w.Header().Add("Vary", "Accept")
+
+	format := data.RuntimeFormat(r.URL.Query().Get("runtime_format"))
+
+	if format == "" {
+		for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+			_, params, err := mime.ParseMediaType(mediaRange)
+			if err == nil && params["runtime"] != "" {
+				format = data.RuntimeFormat(params["runtime"])
+				break
+			}
+		}
+	}
+
+	for _, known := range data.RuntimeFormats {
+		if format == known {
+			for _, movie := range movies {
+				movie.SetRuntimeFormat(format)
+			}
+			return
+		}
+	}
+}
+
 // readString is a helper method on application type that returns a string value from the URL query
 // string, or the provided default value if no matching key is found.
 func (app *application) readStrings(qs url.Values, key string, defaultValue string) string {
@@ -217,6 +342,49 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// readBool is a helper method on application type that reads a string value from the URL query
+// string and converts it to a bool before returning. If no matching key is found then it returns
+// the provided default value. If the value couldn't be converted to a bool, then we record an
+// error message in the provided Validator instance, and return the default value.
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// readTime is a helper method on application type that reads a string value from the URL query
+// string and parses it as either an RFC 3339 timestamp or a date (which is taken to be midnight
+// UTC). If no matching key is found then it returns the zero time. If the value couldn't be
+// parsed, then we record an error message in the provided Validator instance, and return the
+// zero time.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be an RFC 3339 timestamp or a date in the format YYYY-MM-DD")
+	return time.Time{}
+}
+
 // background is a helper that accepts an arbitrary function as a parameter and runs it in a
 // in goroutine in the background.
 func (app *application) background(fn func()) {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := newTestApp()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", ok)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id", app.dispatchParam("id", map[string]http.HandlerFunc{
+		"batch": ok,
+	}, app.methodNotAllowed(router)))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", ok)
+
+	tests := []struct {
+		name      string
+		url       string
+		wantCode  int
+		wantAllow string
+	}{
+		{"Dispatched value", "/v1/movies/batch", http.StatusOK, ""},
+		{"Other value", "/v1/movies/123", http.StatusMethodNotAllowed, "DELETE, GET, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, tt.url, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rr.Code)
+			}
+
+			if allow := rr.Header().Get("Allow"); allow != tt.wantAllow {
+				t.Errorf("want Allow header %q; got %q", tt.wantAllow, allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/imaging"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// maxImageUploadSize is the largest image upload that we accept, in bytes.
+const maxImageUploadSize = 10 << 20
+
+// imageVariants holds the sizes that are generated for each uploaded image. The original
+// variant is re-encoded at its full size, which also strips any metadata from the upload.
+var imageVariants = []imaging.Variant{
+	{Name: "original", Width: 0},
+	{Name: "large", Width: 1280},
+	{Name: "medium", Width: 500},
+	{Name: "small", Width: 185},
+}
+
+// uploadMovieImageHandler handles the "POST /v1/movies/:id/images" endpoint. It accepts a
+// multipart/form-data upload with the image in the "image" field and an optional "kind" field
+// (defaulting to "poster"), stores the resized variants, and returns a JSON response of the new
+// image.
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Limit the size of the request body, leaving a little headroom for the rest of the form.
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageUploadSize+1<<20)
+
+	err = r.ParseMultipartForm(maxImageUploadSize)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body must be a multipart form no larger than %d bytes", maxImageUploadSize))
+		return
+	}
+	defer func() {
+		if err := r.MultipartForm.RemoveAll(); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	}()
+
+	image := &data.MovieImage{
+		MovieID: id,
+		Kind:    strings.TrimSpace(r.FormValue("kind")),
+	}
+	if image.Kind == "" {
+		image.Kind = "poster"
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieImage(v, image); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		v.AddError("image", "must be provided")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	upload, err := io.ReadAll(io.LimitReader(file, maxImageUploadSize+1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v.Check(len(upload) <= maxImageUploadSize, "image", fmt.Sprintf("must not be larger than %d bytes", maxImageUploadSize))
+
+	// Check the content type from the bytes themselves, rather than trusting the filename or the
+	// Content-Type header sent by the client.
+	image.ContentType, err = imaging.Sniff(upload)
+	if err != nil {
+		v.AddError("image", "must be a JPEG, PNG or GIF image")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	processed, err := imaging.Process(upload, imageVariants)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrTooManyPixels):
+			v.AddError("image", fmt.Sprintf("must not have more than %d pixels", imaging.MaxPixels))
+		default:
+			v.AddError("image", "could not be decoded")
+		}
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	original := processed["original"]
+	image.Width = original.Width
+	image.Height = original.Height
+
+	// Store each variant under a random prefix, so that a new upload never overwrites (or is
+	// cached as) an old one.
+	prefix, err := randomHex(16)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	image.Variants = make(data.ImageVariants, len(processed))
+
+	for name, img := range processed {
+		ext := ".jpg"
+		if img.ContentType == "image/png" {
+			ext = ".png"
+		}
+
+		key := fmt.Sprintf("movies/%d/%s/%s%s", id, prefix, name, ext)
+
+		err = app.storage.Put(r.Context(), key, bytes.NewReader(img.Data), img.ContentType)
+		if err != nil {
+			app.deleteImageFiles(image)
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		image.Variants[name] = &data.ImageVariant{Key: key, Width: img.Width, Height: img.Height}
+	}
+
+	err = app.models.MovieImages.Insert(image)
+	if err != nil {
+		// The files have already been stored, so remove them again.
+		app.deleteImageFiles(image)
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.setImageURLs(image)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": image}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteMovieImageHandler handles the "DELETE /v1/movies/:id/images/:image_id" endpoint.
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	imageID, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	image, err := app.models.MovieImages.Delete(id, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteImageFiles(image)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// loadImages embeds the images for each of the provided movies, using a single query for all
+// of them.
+func (app *application) loadImages(movies ...*data.Movie) error {
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	images, err := app.models.MovieImages.GetAllForMovies(ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Images = images[movie.ID]
+		app.setImageURLs(movie.Images...)
+	}
+
+	return nil
+}
+
+// setImageURLs fills in the URL of each image variant from its storage key.
+func (app *application) setImageURLs(images ...*data.MovieImage) {
+	for _, image := range images {
+		for _, variant := range image.Variants {
+			variant.URL = app.storage.URL(variant.Key)
+		}
+	}
+}
+
+// deleteImageFiles removes the stored files for the provided images in the background. Failures
+// are logged rather than returned, since the image records have already gone by this point.
+func (app *application) deleteImageFiles(images ...*data.MovieImage) {
+	var keys []string
+	for _, image := range images {
+		keys = append(keys, image.Variants.Keys()...)
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		for _, key := range keys {
+			err := app.storage.Delete(ctx, key)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"key": key})
+			}
+		}
+	})
+}
+
+// randomHex returns a random hex string made from n random bytes.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
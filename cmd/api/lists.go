@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// readListForRequest fetches the list identified by the "id" URL parameter and checks that the
+// user making the request is allowed to see it (or, if ownerOnly is true, that they own it). If
+// not, an appropriate error response is sent and ok is false. Lists which the user isn't allowed
+// to see get a 404 Not Found response, so that we don't leak the existence of private lists.
+func (app *application) readListForRequest(w http.ResponseWriter, r *http.Request, ownerOnly bool) (*data.List, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	list, err := app.models.Lists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	user := app.contextGetUser(r)
+
+	if !list.VisibleTo(user, r.URL.Query().Get("share_token")) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	if ownerOnly && !list.OwnedBy(user) {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	// Only the owner of a list gets to see its share token.
+	if !list.OwnedBy(user) {
+		list.ShareToken = ""
+	}
+
+	return list, true
+}
+
+// createListHandler handles the "POST /v1/lists" endpoint and returns a JSON response of the
+// newly created list, owned by the authenticated user.
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Lists are private unless the client says otherwise.
+	if input.Visibility == "" {
+		input.Visibility = data.ListPrivate
+	}
+
+	list := &data.List{
+		UserID:      app.contextGetUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Visibility:  input.Visibility,
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Every list gets a share token up front, so that it can be switched to unlisted at any point.
+	err = list.SetShareToken()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Lists.Insert(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d", list.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"list": list}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showListHandler handles the "GET /v1/lists/:id" endpoint and returns a JSON response of the
+// requested list. Unlisted lists can be viewed by anyone who supplies the list's share token in
+// the share_token query string parameter.
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readListForRequest(w, r, false)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateListHandler handles the "PATCH /v1/lists/:id" endpoint and returns a JSON response of the
+// updated list. Only the owner of a list can update it.
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readListForRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("X-Expected-Version") != "" {
+		if strconv.FormatInt(int64(list.Version), 10) != r.Header.Get("X-Expected-Version") {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
+	var input struct {
+		Name                 *string `json:"name"`
+		Description          *string `json:"description"`
+		Visibility           *string `json:"visibility"`
+		RegenerateShareToken bool    `json:"regenerate_share_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		list.Name = *input.Name
+	}
+
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+
+	if input.Visibility != nil {
+		list.Visibility = *input.Visibility
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Regenerating the share token revokes access for anyone holding the old unlisted link.
+	if input.RegenerateShareToken {
+		err = list.SetShareToken()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Lists.Update(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteListHandler handles the "DELETE /v1/lists/:id" endpoint. Only the owner of a list can
+// delete it.
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readListForRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.Delete(list.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listListsHandler handles the "GET /v1/lists" endpoint and returns a paginated JSON response of
+// lists. By default these are the authenticated user's own lists, but scope=public returns the
+// public lists of all users instead.
+func (app *application) listListsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Scope string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Scope = app.readStrings(qs, "scope", "mine")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readStrings(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	v.Check(validator.In(input.Scope, "mine", "public"), "scope", "must be either mine or public")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	var (
+		lists    []*data.List
+		metadata data.Metadata
+		err      error
+	)
+
+	switch input.Scope {
+	case "public":
+		lists, metadata, err = app.models.Lists.GetAll(0, data.ListPublic, input.Filters)
+	default:
+		lists, metadata, err = app.models.Lists.GetAll(user.ID, "", input.Filters)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Public lists may belong to other users, so don't expose their share tokens.
+	for _, list := range lists {
+		if !list.OwnedBy(user) {
+			list.ShareToken = ""
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listListEntriesHandler handles the "GET /v1/lists/:id/entries" endpoint and returns a paginated
+// JSON response of the movies on a list, in list order by default.
+func (app *application) listListEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readStrings(qs, "sort", "position")
+	input.Filters.SortSafeList = []string{"position", "added_at", "-position", "-added_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	list, ok := app.readListForRequest(w, r, false)
+	if !ok {
+		return
+	}
+
+	entries, metadata, err := app.models.Lists.GetEntries(list.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"entries": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addListEntryHandler handles the "POST /v1/lists/:id/entries" endpoint, which adds a movie to a
+// list. If no position is given the movie is added to the end of the list.
+func (app *application) addListEntryHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readListForRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MovieID  int64  `json:"movie_id"`
+		Position int32  `json:"position"`
+		Notes    string `json:"notes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entry := &data.ListEntry{
+		ListID:   list.ID,
+		MovieID:  input.MovieID,
+		Position: input.Position,
+		Notes:    input.Notes,
+	}
+
+	v := validator.New()
+
+	if data.ValidateListEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.AddEntry(entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateListEntry):
+			v.AddError("movie_id", "this movie is already on the list")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "movie does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d/entries/%d", list.ID, entry.MovieID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"entry": entry}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateListEntryHandler handles the "PATCH /v1/lists/:id/entries/:movie_id" endpoint, which
+// moves an entry to a new position on the list and/or updates its notes.
+func (app *application) updateListEntryHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readListForRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	entry, err := app.models.Lists.GetEntry(list.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Position *int32  `json:"position"`
+		Notes    *string `json:"notes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// A zero position tells UpdateEntry to leave the entry where it is.
+	entry.Position = 0
+	if input.Position != nil {
+		entry.Position = *input.Position
+	}
+
+	if input.Notes != nil {
+		entry.Notes = *input.Notes
+	}
+
+	v := validator.New()
+
+	v.Check(input.Position == nil || *input.Position > 0, "position", "must be a positive integer")
+
+	if data.ValidateListEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.UpdateEntry(entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeListEntryHandler handles the "DELETE /v1/lists/:id/entries/:movie_id" endpoint, which
+// removes a movie from a list.
+func (app *application) removeListEntryHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readListForRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.RemoveEntry(list.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "entry successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/DataDavD/snippetbox/greenlight/internal/data"
 	"github.com/DataDavD/snippetbox/greenlight/internal/jsonlog"
 	"github.com/DataDavD/snippetbox/greenlight/internal/mailer"
+	"github.com/DataDavD/snippetbox/greenlight/internal/openapi"
+	"github.com/DataDavD/snippetbox/greenlight/internal/storage"
 	"github.com/DataDavD/snippetbox/greenlight/internal/vcs"
 
 	// Import the pq driver so that it can register itself with the database/sql
@@ -57,16 +59,50 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	// storage holds the settings for the backend that uploaded images are stored in. Only the
+	// local filesystem backend is currently available.
+	storage struct {
+		backend string
+		dir     string
+		baseURL string
+	}
+	// cursor holds the secret used to sign the pagination cursors handed out to clients. If it
+	// isn't set, a random secret is generated on startup, so cursors don't survive a restart.
+	cursor struct {
+		secret string
+	}
+	// preconditions holds whether writes to movies must be conditional, using an If-Match (or
+	// X-Expected-Version) header.
+	preconditions struct {
+		required bool
+	}
+	// releases holds how often movies are promoted to released on their release date. Zero
+	// disables the promotion job.
+	releases struct {
+		interval time.Duration
+	}
+	// openapi holds whether requests are validated against the OpenAPI document before they
+	// reach the handlers.
+	openapi struct {
+		validate bool
+	}
+	// graphql holds the limits on the depth and cost of the queries sent to the GraphQL endpoint.
+	graphql struct {
+		maxDepth int
+		maxCost  int
+	}
 }
 
 // Define an application struct to hold dependencies for our HTTP handlers, helpers, and
 // middleware.
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *jsonlog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	storage storage.Storage
+	openapi *openapi.Spec
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -121,6 +157,28 @@ func main() {
 		return nil
 	})
 
+	// Read the image storage settings.
+	flag.StringVar(&cfg.storage.backend, "storage-backend", "local", "Image storage backend (local)")
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Image storage directory for the local backend")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "/v1/images", "Base URL that stored images are served from")
+
+	// Read the pagination cursor secret.
+	flag.StringVar(&cfg.cursor.secret, "cursor-secret", os.Getenv("CURSOR_SECRET"), "Secret for signing pagination cursors")
+
+	// Read the setting for requiring conditional writes.
+	flag.BoolVar(&cfg.preconditions.required, "require-preconditions", false, "Reject movie writes without an If-Match header")
+
+	// Read the interval of the release promotion job.
+	flag.DurationVar(&cfg.releases.interval, "release-promotion-interval", time.Hour,
+		"How often to promote movies to released on their release date (0 disables)")
+
+	// Read the setting for validating requests against the OpenAPI document.
+	flag.BoolVar(&cfg.openapi.validate, "openapi-validate", false, "Validate requests against the OpenAPI document")
+
+	// Read the limits for GraphQL queries.
+	flag.IntVar(&cfg.graphql.maxDepth, "graphql-max-depth", 8, "Maximum nesting depth of a GraphQL query")
+	flag.IntVar(&cfg.graphql.maxCost, "graphql-max-cost", 10000, "Maximum cost of a GraphQL query")
+
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
@@ -172,12 +230,36 @@ func main() {
 		return time.Now().Unix()
 	}))
 
+	if cfg.cursor.secret == "" {
+		cfg.cursor.secret, err = randomHex(32)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		logger.PrintInfo("no cursor secret set, pagination cursors will not survive a restart", nil)
+	}
+
+	// Open the image storage backend.
+	store, err := openStorage(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	// Declare an instance of the application struct, containing the config struct and the infoLog.
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		models:  data.NewModels(db),
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		storage: store,
+	}
+
+	// Load the OpenAPI document if requests are to be validated against it.
+	if cfg.openapi.validate {
+		app.openapi, err = openapi.Load(openAPISpec)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
 	}
 
 	// Call app.server() to start the server.
@@ -228,3 +310,13 @@ func openDB(cfg config) (*sql.DB, error) {
 	// Return the sql.DB connection pool.
 	return db, nil
 }
+
+// openStorage returns the image storage backend selected in the config.
+func openStorage(cfg config) (storage.Storage, error) {
+	switch cfg.storage.backend {
+	case "local":
+		return storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.storage.backend)
+	}
+}
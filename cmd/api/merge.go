@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// mergeMovieHandler handles the "POST /v1/movies/:id/merge" endpoint. It folds the movie into
+// the movie given by "target_id" in the request body, deleting it and redirecting any later
+// lookups of its ID, and returns a JSON response of the merged target movie. It requires the
+// movies:admin permission, which can be granted with "make db/permissions/grant".
+func (app *application) mergeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		TargetID int64 `json:"target_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.TargetID > 0, "target_id", "must be a positive integer")
+	v.Check(input.TargetID != id, "target_id", "must not be the movie being merged")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Check that the target exists first, so that ErrRecordNotFound from the merge below can only
+	// mean that the movie being merged doesn't exist.
+	_, err = app.models.Movies.Get(input.TargetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("target_id", "movie does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Movies.Merge(id, input.TargetID, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Fetch the target again, so that the response includes its updated rating aggregates.
+	movie, err := app.models.Movies.Get(input.TargetID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.loadRelated(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// redirectMergedMovie responds to a request for a movie ID which doesn't exist. Reads of a movie
+// which was merged into another one get a 301 Moved Permanently response pointing at the merge
+// target. Writes aren't redirected, since following the redirect would apply a change meant for
+// one movie to another, so they get a 404 Not Found response which still names the target. IDs
+// which were never merged get the usual 404 Not Found response.
+func (app *application) redirectMergedMovie(w http.ResponseWriter, r *http.Request, id int64) {
+	target, err := app.models.Movies.GetRedirect(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	location := fmt.Sprintf("/v1/movies/%d", target)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		env := envelope{"error": fmt.Sprintf("this movie has been merged into %s", location), "movie_id": target}
+
+		err = app.writeJSON(w, http.StatusNotFound, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", location)
+
+	env := envelope{"message": fmt.Sprintf("this movie has been merged into %s", location), "movie_id": target}
+
+	err = app.writeJSON(w, http.StatusMovedPermanently, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
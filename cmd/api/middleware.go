@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"expvar"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
@@ -217,22 +220,51 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthenticatedUser(fn)
 }
 
+// The errors returned by checkPermission when a user can't use a permission.
+var (
+	errAuthenticationRequired = errors.New("authentication required")
+	errInactiveAccount        = errors.New("inactive account")
+	errNotPermitted           = errors.New("not permitted")
+)
+
+// checkPermission checks that the user is authenticated, activated and has the permission with
+// the given code, returning errAuthenticationRequired, errInactiveAccount or errNotPermitted if
+// not. It's used by requirePermissions, and by the GraphQL resolvers which check permissions
+// field by field.
+func (app *application) checkPermission(user *data.User, code string) error {
+	switch {
+	case user.IsAnonymous():
+		return errAuthenticationRequired
+	case !user.Activated:
+		return errInactiveAccount
+	}
+
+	// Get the slice of permission for the user
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the slice includes the required permission.
+	if !permissions.Include(code) {
+		return errNotPermitted
+	}
+
+	return nil
+}
+
 func (app *application) requirePermissions(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Retrieve the user from the request context.
-		user := app.contextGetUser(r)
-
-		// Get the slice of permission for the user
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		// Check that the user from the request context has the required permission. If they
+		// don't, then return a 403 Forbidden response.
+		err := app.checkPermission(app.contextGetUser(r), code)
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-
-		// Check if the slice includes the required permission. If it doesn't, then return a 403
-		// Forbidden response.
-		if !permissions.Include(code) {
-			app.notPermittedResponse(w, r)
+			switch {
+			case errors.Is(err, errNotPermitted):
+				app.notPermittedResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
 			return
 		}
 
@@ -244,6 +276,72 @@ func (app *application) requirePermissions(code string, next http.HandlerFunc) h
 	return app.requireActivatedUser(fn)
 }
 
+// validateRequests checks the query parameters and JSON body of each request against the
+// operation for its route in the OpenAPI document, and sends a 422 Unprocessable Entity response
+// listing the invalid fields before the handler runs. Requests to operations which require an
+// activated user, or a permission, are passed straight through if the user isn't one or doesn't
+// have it, so that they get the usual 401 or 403 response instead. Domain rules, such as those in
+// data.ValidateMovie, are still checked by the handlers.
+func (app *application) validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := app.openapi.Find(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := app.contextGetUser(r)
+
+		switch {
+		case op.Permission != "":
+			// Any error, including a failure to look up the user's permissions, is left for
+			// requirePermissions to respond to.
+			if app.checkPermission(user, op.Permission) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		case op.Secured() && (user.IsAnonymous() || !user.Activated):
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+
+		op.ValidateQuery(v, r.URL.Query())
+
+		// Bodies without a Content-Type header are read as JSON by readJSON, so they're validated
+		// as JSON too.
+		mediaType := "application/json"
+		if r.Header.Get("Content-Type") != "" {
+			mediaType, _, _ = mime.ParseMediaType(r.Header.Get("Content-Type"))
+		}
+
+		if mediaType == "application/json" && op.BodySchema(mediaType) != nil && r.Body != nil {
+			// Read the body up to the same limit as readJSON, and put it back for the handler.
+			// Larger bodies aren't validated, so that readJSON can report them as usual.
+			maxBytes := 1_048_576
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, int64(maxBytes)+1))
+			if err != nil {
+				app.badRequestResponse(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+			if len(body) <= maxBytes {
+				op.ValidateBody(v, mediaType, body)
+			}
+		}
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // enableCORS sets the Vary: Origin and Access-Control-Allow-Origin response headers in order to
 // enabled CORS for trusted origins.
 func (app *application) enableCORS(next http.Handler) http.Handler {
@@ -268,13 +366,16 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 					// header with the request origin as the value and break out of the loop.
 					w.Header().Set("Access-Control-Allow-Origin", origin)
 
+					// Let browsers read the ETag header, for use in conditional requests.
+					w.Header().Set("Access-Control-Expose-Headers", "ETag")
+
 					// Check if the request has the HTTP method OPTIONS and contains the
 					// "Access-Control-Request-Method" header. If it does, then we treat it as a
 					// preflight request.
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						// Set the necessary preflight response headers.
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 
 						// Set max cached times for headers for 60 seconds.
 						w.Header().Set("Access-Control-Max-Age", "60")
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/DataDavD/snippetbox/greenlight/internal/data"
 	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
@@ -18,10 +23,18 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	// request body (not that the field names and types in the struct are a subset of the Movie
 	// struct). This struct will be our *target decode destination*.
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title          string              `json:"title"`
+		Year           int32               `json:"year"`
+		Runtime        data.Runtime        `json:"runtime"`
+		Genres         []string            `json:"genres"`
+		Synopsis       string              `json:"synopsis"`
+		OriginalTitle  string              `json:"original_title"`
+		Languages      []string            `json:"languages"`
+		Certifications data.Certifications `json:"certifications"`
+		ReleaseStatus  string              `json:"release_status"`
+		ReleaseDates   data.ReleaseDates   `json:"release_dates"`
+		IMDbID         string              `json:"imdb_id"`
+		TMDBID         int64               `json:"tmdb_id"`
 	}
 
 	// Use the readJSON() helper to decode the request body into the struct.
@@ -35,28 +48,75 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Copy the values from the input struct to a new Movie struct.
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:          input.Title,
+		Year:           input.Year,
+		Runtime:        input.Runtime,
+		Genres:         input.Genres,
+		Synopsis:       input.Synopsis,
+		OriginalTitle:  input.OriginalTitle,
+		Languages:      input.Languages,
+		Certifications: input.Certifications,
+		ReleaseStatus:  input.ReleaseStatus,
+		ReleaseDates:   input.ReleaseDates,
+		IMDbID:         input.IMDbID,
+		TMDBID:         input.TMDBID,
+	}
+
+	// Fetch the genre taxonomy, which ValidateMovie uses to normalise the movie's genres.
+	genres, err := app.models.Genres.Index()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	// Initialize a new Validator instance.
 	v := validator.New()
 
+	// Read the force query string parameter, which skips the duplicate check below.
+	force := app.readBool(r.URL.Query(), "force", false, v)
+
 	// Call the ValidateMovie() function and return a response containing the errors if any of
 	// the checks fail.
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	// Look for existing movies which this one might duplicate. If there is one with the same
+	// normalised title and year, then refuse to create the movie unless the client has forced
+	// the insert. Weaker matches are returned alongside the new movie as a warning.
+	var duplicates []*data.DuplicateCandidate
+
+	if !force {
+		duplicates, err = app.models.Movies.FindDuplicates(movie.Title, movie.Year)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, duplicate := range duplicates {
+			if duplicate.Exact {
+				app.duplicateMovieResponse(w, r, duplicates)
+				return
+			}
+		}
+	}
+
 	// Call the Insert() method on our movies model, passing in a pointer to the validated movie
-	// struct. This will create a record in the database and update the movie struct with the
-	// system-generated information.
-	err = app.models.Movies.Insert(movie)
+	// struct and the ID of the user making the change. This will create a record in the database
+	// and update the movie struct with the system-generated information.
+	err = app.models.Movies.Insert(movie, app.contextGetUser(r).ID)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDBID):
+			v.AddError("tmdb_id", "a movie with this TMDB ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	// When sending an HTTP response,
@@ -67,9 +127,16 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
 
+	app.setRuntimeFormat(w, r, movie)
+
 	// Write a JSON response with a 201 Created status code, the movie data in the response body,
 	// and the Location header.
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	env := envelope{"movie": movie}
+	if len(duplicates) > 0 {
+		env["possible_duplicates"] = duplicates
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -96,16 +163,47 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			// The movie may have been merged into another one, in which case we redirect the
+			// client to it.
+			app.redirectMergedMovie(w, r, id)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	app.setRuntimeFormat(w, r, movie)
+
+	// Choose the title from the client's preferred languages.
+	err = app.localiseTitles(w, r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Embed the movie's credits and images in the response.
+	err = app.loadRelated(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Create an envelope{"movie": movie} instance and pass it to writeJSON(), instead of passing
 	// the plain movie struct.
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	env := envelope{"movie": movie}
+
+	// Send a 304 Not Modified response if the client already has this representation of the
+	// movie.
+	notModified, err := app.notModified(w, r, movie, env)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if notModified {
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -113,7 +211,8 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 
 // updateMovieHandler handles "PATCH /v1/movies/:id" endpoint and returns a JSON response
 // of the updated movie record. If there is an error a JSON formatted error is
-// returned.
+// returned. The body can be a plain JSON object of the fields to change, or a JSON Merge Patch
+// or JSON Patch document with the matching Content-Type.
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the movie ID from the URL.
 	id, err := app.readIDParam(r)
@@ -123,43 +222,120 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Fetch the existing movie record from the database.
-	// Send a 404 Not Found response to the client if we couldn't find a matching record.
+	// Send a 404 Not Found response to the client if we couldn't find a matching record, which
+	// says so if the movie was merged into another one.
 	movie, err := app.models.Movies.Get(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.redirectMergedMovie(w, r, id)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	// If the request contains an X-Expected-Version, verify that the movie version in the database
-	// matches the expected version specified in the header.
-	if r.Header.Get("X-Expected-Version") != "" {
-		if strconv.FormatInt(int64(movie.Version), 10) != r.Header.Get("X-Expected-Version") {
-			app.editConflictResponse(w, r)
+	// Check that the movie hasn't changed since the client fetched it, if the request contains
+	// an If-Match or X-Expected-Version header.
+	if !app.checkPreconditions(w, r, movie) {
+		return
+	}
+
+	// Apply the request body to the movie record. JSON Merge Patch and JSON Patch documents
+	// (identified by their Content-Type) are applied to the movie's JSON representation, and
+	// any other body is read as a moviePatch.
+	if mediaType := patchMediaType(r); mediaType != "" {
+		err = app.applyMoviePatch(w, r, mediaType, movie)
+		if err != nil {
+			app.patchFailedResponse(w, r, err)
 			return
 		}
+	} else {
+		var input moviePatch
+
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		input.apply(movie)
 	}
 
-	// Use pointers for Title, Year, and Runtime fields, so that we can use their zero values of
-	// nil as part of the partial record update logic. Slice's zero value is already nil.
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+	genres, err := app.models.Genres.Index()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Validate the updated movie record,
+	// sending the client a 422 Unprocessable Entity response if any checks fails
+	v := validator.New()
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	// Read the JSON request body data into the input struct.
-	err = app.readJSON(w, r, &input)
+	// Pass the updated movie record to the Update() method, along with the ID of the user making
+	// the change so that it can be recorded in the movie's revision history.
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDBID):
+			v.AddError("tmdb_id", "a movie with this TMDB ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+
+		}
 		return
 	}
 
+	err = app.loadRelated(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.setRuntimeFormat(w, r, movie)
+
+	// Write the updated movie record in a JSON response, along with its new ETag.
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// moviePatch holds the fields of a partial movie update. Use pointers for Title, Year, and
+// Runtime fields, so that we can use their zero values of nil as part of the partial record
+// update logic. Slice's zero value is already nil.
+type moviePatch struct {
+	Title          *string             `json:"title"`
+	Year           *int32              `json:"year"`
+	Runtime        *data.Runtime       `json:"runtime"`
+	Genres         []string            `json:"genres"`
+	Synopsis       *string             `json:"synopsis"`
+	OriginalTitle  *string             `json:"original_title"`
+	Languages      []string            `json:"languages"`
+	Certifications data.Certifications `json:"certifications"`
+	ReleaseStatus  *string             `json:"release_status"`
+	ReleaseDates   data.ReleaseDates   `json:"release_dates"`
+	IMDbID         *string             `json:"imdb_id"`
+	TMDBID         *int64              `json:"tmdb_id"`
+}
+
+// apply copies the fields which were provided in the patch to the movie record.
+func (input moviePatch) apply(movie *data.Movie) {
 	// If the input.Title value is nil then we know that no corresponding "title" key/value pair
 	// was provided in the JSON request body. So, we move on and leave the movie record unchanged.
 	// Otherwise, we update the movie record with the new title value. Importantly, because
@@ -182,34 +358,41 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		movie.Genres = input.Genres // Note that we don't need to dereference a slice because its zero is already nil
 	}
 
-	// Validate the updated movie record,
-	// sending the client a 422 Unprocessable Entity response if any checks fails
-	v := validator.New()
+	if input.Synopsis != nil {
+		movie.Synopsis = *input.Synopsis
+	}
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
+	if input.OriginalTitle != nil {
+		movie.OriginalTitle = *input.OriginalTitle
 	}
 
-	// Pass the updated movie record to the Update() method.
-	err = app.models.Movies.Update(movie)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
+	if input.Languages != nil {
+		movie.Languages = input.Languages
+	}
 
-		}
-		return
+	// Certifications replace the existing set as a whole, like languages and genres. Send an
+	// empty object to clear them.
+	if input.Certifications != nil {
+		movie.Certifications = input.Certifications
 	}
 
-	// Write the updated movie record in a JSON response.
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	if input.ReleaseStatus != nil {
+		movie.ReleaseStatus = *input.ReleaseStatus
+	}
+
+	// Release dates also replace the existing set as a whole.
+	if input.ReleaseDates != nil {
+		movie.ReleaseDates = input.ReleaseDates
+	}
+
+	// An external ID can be removed by setting it to its zero value ("" or 0).
+	if input.IMDbID != nil {
+		movie.IMDbID = *input.IMDbID
 	}
 
+	if input.TMDBID != nil {
+		movie.TMDBID = *input.TMDBID
+	}
 }
 
 // deleteMovieHandler handles "DELETE /v1/movies/:id" endpoint and returns a 200 OK status code
@@ -223,19 +406,48 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Fetch the movie, so that the request's preconditions can be checked against it. If it was
+	// merged into another movie, the 404 Not Found response says so.
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.redirectMergedMovie(w, r, id)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.checkPreconditions(w, r, movie) {
+		return
+	}
+
+	// Look up the movie's images before deleting it. The image records are removed along with
+	// the movie, but their files need removing from storage separately.
+	images, err := app.models.MovieImages.GetAllForMovies(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Delete the movie from the database. Send a 404 Not Found response to the client if
 	// there isn't a matching record.
-	err = app.models.Movies.Delete(id)
+	err = app.models.Movies.Delete(id, movie.Version, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	app.deleteImageFiles(images[id]...)
+
 	// Return a 200 OK status code along with a success message.
 	err = app.writeJSON(w, 200, envelope{"message": "movie successfully deleted"}, nil)
 	if err != nil {
@@ -243,43 +455,219 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// loadRelated embeds the credits, collections and images for each of the provided movies.
+func (app *application) loadRelated(movies ...*data.Movie) error {
+	err := app.loadCredits(movies...)
+	if err != nil {
+		return err
+	}
+
+	err = app.loadCollections(movies...)
+	if err != nil {
+		return err
+	}
+
+	return app.loadImages(movies...)
+}
+
+// loadCredits embeds the credits for each of the provided movies, using a single query for all
+// of them.
+func (app *application) loadCredits(movies ...*data.Movie) error {
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	credits, err := app.models.Credits.GetAllForMovies(ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Credits = credits[movie.ID]
+	}
+
+	return nil
+}
+
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title        string
-		Genres       []string
-		data.Filters // Embed the Filters struct type which holds fields for filtering and sorting.
+		data.MovieFilters // Embed the MovieFilters struct type which holds the movie filters.
+		data.Filters      // Embed the Filters struct type which holds fields for filtering and sorting.
 	}
 
+	// Start from filters which don't filter anything, so that the slice filters which aren't
+	// provided are empty rather than nil.
+	input.MovieFilters = data.NewMovieFilters()
+
 	// Initialize a new Validator instance.
 	v := validator.New()
 
 	// call r.URL.Query() to get the url.Values map containing the query string data.
 	qs := r.URL.Query()
 
-	// Use our helpers to extract the title and genres query string values, falling back to the
+	// Use our helpers to extract the search and genres query string values, falling back to the
 	// defaults of an empty string and an empty slice, respectively, if they are not provided
-	// by the client.
-	input.Title = app.readStrings(qs, "title", "")
+	// by the client. The search query is read from "q", or from "title" for older clients, and
+	// converted to tsquery syntax.
+	search := app.readStrings(qs, "q", app.readStrings(qs, "title", ""))
+	input.Search = data.ParseSearchQuery(search)
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.GenresAny = app.readCSV(qs, "genres_any", []string{})
+	input.GenresNone = app.readCSV(qs, "genres_none", []string{})
+
+	// Map the genres filter onto canonical genre names, so that aliases like "sci-fi" match.
+	// Unknown genres are left as they are, and so will simply match no movies.
+	genres, err := app.models.Genres.Index()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, filter := range [][]string{input.Genres, input.GenresAny, input.GenresNone} {
+		for i, genre := range filter {
+			if canonical, ok := genres.Canonical(genre); ok {
+				filter[i] = canonical
+			}
+		}
+	}
+
+	// Read the person and role filters, which restrict the results to the movies that a person
+	// is credited on (optionally in a specific role).
+	input.PersonID = int64(app.readInt(qs, "person", 0, v))
+	input.Role = app.readStrings(qs, "role", "")
+
+	// Read the metadata filters. The certification filter takes the form "US" (any certification
+	// in the country) or "US:PG-13" (a specific certification).
+	input.Language = app.readStrings(qs, "language", "")
+	input.CertificationCountry, input.Certification, _ = strings.Cut(app.readStrings(qs, "certification", ""), ":")
+	input.IMDbID = app.readStrings(qs, "imdb_id", "")
+	input.TMDBID = int64(app.readInt(qs, "tmdb_id", 0, v))
+
+	// Read the IDs filter, which fetches a batch of up to 100 movies by their IDs.
+	for _, id := range app.readCSV(qs, "ids", []string{}) {
+		i, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || i < 1 {
+			v.AddError("ids", "must be a comma-separated list of movie IDs")
+			break
+		}
+		input.IDs = append(input.IDs, i)
+	}
+	v.Check(len(input.IDs) <= 100, "ids", "must not contain more than 100 IDs")
+
+	// Read the collection filter, which only includes the movies in a collection.
+	input.CollectionID = int64(app.readInt(qs, "collection", 0, v))
+	v.Check(input.CollectionID >= 0, "collection", "must be a positive integer")
+
+	// Read the release status filter, e.g. "announced" to list upcoming movies.
+	input.ReleaseStatus = app.readStrings(qs, "release_status", "")
+
+	// Read the facet filters, which each accept a comma-separated list of values to match any
+	// of, and the list of facets to count.
+	for _, decade := range app.readCSV(qs, "decade", []string{}) {
+		d, err := strconv.ParseInt(decade, 10, 64)
+		if err != nil || d%10 != 0 {
+			v.AddError("decade", "must be a comma-separated list of decades, e.g. 1990")
+			break
+		}
+		input.Decades = append(input.Decades, d)
+	}
+
+	input.RuntimeBuckets = app.readCSV(qs, "runtime", []string{})
+	for _, bucket := range input.RuntimeBuckets {
+		v.Check(validator.In(bucket, data.RuntimeBuckets...), "runtime",
+			fmt.Sprintf("must be a comma-separated list of %s", strings.Join(data.RuntimeBuckets, ", ")))
+	}
+
+	// Read the range filters. The year and runtime ranges are inclusive, and the created range
+	// is exclusive.
+	input.YearMin = int32(app.readInt(qs, "year_min", 0, v))
+	input.YearMax = int32(app.readInt(qs, "year_max", 0, v))
+	input.RuntimeMin = int32(app.readInt(qs, "runtime_min", 0, v))
+	input.RuntimeMax = int32(app.readInt(qs, "runtime_max", 0, v))
+	input.CreatedAfter = app.readTime(qs, "created_after", v)
+	input.CreatedBefore = app.readTime(qs, "created_before", v)
+
+	facets := app.readCSV(qs, "facets", []string{})
+	for _, facet := range facets {
+		v.Check(validator.In(facet, data.MovieFacets...), "facets",
+			fmt.Sprintf("must be a comma-separated list of %s", strings.Join(data.MovieFacets, ", ")))
+	}
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+
+	// When fetching a batch of movies by ID, the page size defaults to fitting them all in.
+	defaultPageSize := 20
+	if len(input.IDs) > defaultPageSize {
+		defaultPageSize = len(input.IDs)
+	}
 
 	// Ge the page and page_size query string value as integers. Notice that we set the default
 	// page value to 1 and default page_size to 20, and that we pass the validator instance
 	// as the final argument.
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
-	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", defaultPageSize, v)
+
+	// Clients opt in to keyset pagination by sending the "cursor" parameter, empty for the first
+	// page and then set to the next_cursor from the previous page. The total number of movies
+	// is only counted in this mode if include_total is set.
+	useCursor := qs.Has("cursor")
+	includeTotal := app.readBool(qs, "include_total", false, v)
+
+	var after *data.Cursor
+	if useCursor && qs.Get("cursor") != "" {
+		after, err = data.DecodeCursor([]byte(app.config.cursor.secret), qs.Get("cursor"))
+		if err != nil {
+			v.AddError("cursor", "is invalid")
+		}
+	}
+
+	v.Check(!useCursor || !qs.Has("page"), "page", "cannot be used with a cursor")
 
 	// Extract the sort query string value, falling back to "id" if it is not provided
-	// by the client (which will imply an ascending sort on movie ID).
-	input.Filters.Sort = app.readStrings(qs, "sort", "id")
+	// by the client (which will imply an ascending sort on movie ID). When searching, we
+	// fall back to sorting by relevance instead, most relevant first.
+	defaultSort := "id"
+	if input.Search != "" {
+		defaultSort = "-relevance"
+	}
+	input.Filters.Sort = app.readStrings(qs, "sort", defaultSort)
 
-	// Add the supported sort value for this endpoint to the sort safelist.
+	// Add the supported sort value for this endpoint to the sort safelist. The sort can be a
+	// comma-separated list of these, such as "-year,title".
 	input.Filters.SortSafeList = []string{
 		// ascending sort values
-		"id", "title", "year", "runtime",
+		"id", "title", "year", "runtime", "average_rating", "rating_count", "relevance",
 		// descending sort values
-		"-id", "-title", "-year", "-runtime",
+		"-id", "-title", "-year", "-runtime", "-average_rating", "-rating_count", "-relevance",
+	}
+
+	// Read the sparse fieldset, which limits the fields returned for each movie, and add the
+	// fields which can be requested to the field safelist.
+	input.Filters.Fields = app.readCSV(qs, "fields", nil)
+	input.Filters.FieldSafeList = []string{
+		"id", "title", "title_locale", "year", "runtime", "genres", "synopsis", "original_title",
+		"languages", "certifications", "release_status", "release_dates", "imdb_id", "tmdb_id",
+		"average_rating", "rating_count", "credits", "collections", "images", "relevance", "highlights", "version",
+	}
+
+	// A cursor can only be used to carry on through the same list that it came from.
+	if after != nil {
+		v.Check(after.Sort == input.Filters.Sort && after.Query == cursorFingerprint(qs), "cursor",
+			"does not match the sort and filters of this request")
+
+		// Carry on with the fuzzy fallback if the first page fell back to it.
+		if after.Fuzzy != "" {
+			input.Search = ""
+			input.Fuzzy = after.Fuzzy
+		}
 	}
 
+	v.Check(input.PersonID >= 0, "person", "must be a positive integer")
+	v.Check(input.Role == "" || input.PersonID > 0, "role", "can only be used with the person filter")
+	v.Check(input.Role == "" || validator.In(input.Role, data.CreditRoles...), "role", "invalid role")
+
+	data.ValidateMovieFilters(v, input.MovieFilters)
+
 	// Execute the validation checks on the Filters struct and send a response
 	// containing the errors if necessary.
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
@@ -287,16 +675,186 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Call the MovieModel.GetAll method to retrieve the movies, passing in the various filter
-	// parameters.
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	// Retrieve the page of movies, passing in the various filter parameters, and using either
+	// MovieModel.GetAll or MovieModel.GetAllAfter depending on the pagination mode.
+	var next *data.Cursor
+
+	getMovies := func() ([]*data.Movie, data.Metadata, error) {
+		if !useCursor {
+			return app.models.Movies.GetAll(input.MovieFilters, input.Filters)
+		}
+
+		movies, metadata, cursor, err := app.models.Movies.GetAllAfter(input.MovieFilters, input.Filters, after, includeTotal)
+		next = cursor
+		return movies, metadata, err
+	}
+
+	movies, metadata, err := getMovies()
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	// If the search didn't match anything, it may be because of a typo. So fall back to fuzzy
+	// matching the search text against titles, and suggest similar titles. This is only done
+	// for the first page, as later pages of a search have already matched something.
+	if input.Search != "" && len(movies) == 0 && after == nil {
+		text := data.SearchText(search)
+
+		if text != "" {
+			suggestions, err := app.models.Movies.Suggest(text, 5)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			input.Search = ""
+			input.Fuzzy = text
+
+			movies, metadata, err = getMovies()
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			metadata.Suggestions = suggestions
+		}
+	}
+
+	// Flag fuzzy matches, including on later pages of a fuzzy fallback.
+	if input.Fuzzy != "" {
+		metadata.FuzzyMatch = true
+	}
+
+	// Sign the cursor for the next page, binding it to the filters of this request.
+	if next != nil {
+		next.Query = cursorFingerprint(qs)
+		next.Fuzzy = input.Fuzzy
+
+		metadata.NextCursor, err = next.Encode([]byte(app.config.cursor.secret))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// Embed the credits, collections and images for the page of movies, unless they've been left
+	// out of the sparse fieldset.
+	if wantField(input.Filters.Fields, "credits") {
+		err = app.loadCredits(movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if wantField(input.Filters.Fields, "collections") {
+		err = app.loadCollections(movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if wantField(input.Filters.Fields, "images") {
+		err = app.loadImages(movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if wantLocalisedTitles(input.Filters.Fields) {
+		err = app.localiseTitles(w, r, movies...)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.setRuntimeFormat(w, r, movies...)
+
+	env := envelope{"movies": movies, "metadata": metadata}
+
+	// Only serialise the requested fields, if a sparse fieldset was requested.
+	if input.Filters.Fields != nil {
+		sparse := make([]map[string]json.RawMessage, len(movies))
+
+		for i, movie := range movies {
+			sparse[i], err = selectFields(movie, input.Filters.Fields)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		env["movies"] = sparse
+	}
+
+	// Count the facets, if any were requested, for the same filters as the movies.
+	if len(facets) > 0 {
+		counts, err := app.models.Movies.Facets(input.MovieFilters, facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		env["facets"] = counts
+	}
+
 	// Send a JSON response containing the movie data.
-	if err := app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil); err != nil {
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// cursorFingerprint returns a fingerprint of the query string parameters which affect the list
+// of movies, so that a pagination cursor can be tied to the list it came from. Parameters which
+// only affect the response (such as the page size) are left out.
+func cursorFingerprint(qs url.Values) string {
+	filters := url.Values{}
+
+	for key, values := range qs {
+		switch key {
+		case "cursor", "include_total", "page_size", "facets", "fields", "runtime_format":
+			continue
+		}
+		filters[key] = values
+	}
+
+	sum := sha256.Sum256([]byte(filters.Encode()))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// autocompleteMoviesHandler handles the "GET /v1/movies/autocomplete" endpoint and returns a JSON
+// response of up to "limit" (default 10) title completions for the text in "q".
+func (app *application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	text := strings.TrimSpace(app.readStrings(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(text != "", "q", "must be provided")
+	v.Check(len(text) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than 0")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	completions, err := app.models.Movies.Autocomplete(text, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"completions": completions}, nil)
+	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
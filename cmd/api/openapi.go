@@ -0,0 +1,22 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec holds the OpenAPI 3 document describing every route registered in app.routes().
+// It's kept up to date by hand, and TestOpenAPICoversRoutes fails if a route is missing from it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIHandler handles the "GET /v1/openapi.json" endpoint and returns the API's OpenAPI 3
+// document.
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openAPISpec); err != nil {
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/openapi"
+)
+
+// openAPIOperation holds the parts of an OpenAPI operation that are checked against the routes.
+type openAPIOperation struct {
+	OperationID string `json:"operationId"`
+	Permission  string `json:"x-permission"`
+	Responses   map[string]json.RawMessage
+}
+
+// specRoute describes a route registered in app.routes(). Handler is the name of the handler
+// method (if the route has one), and Permission is the permission it requires (if any).
+type specRoute struct {
+	Method     string
+	Path       string
+	Handler    string
+	Permission string
+}
+
+// routeParamRX matches the httprouter named and catch-all parameters in a path, e.g. ":id".
+var routeParamRX = regexp.MustCompile(`[:*]([a-z_]+)`)
+
+// registeredRoutes parses routes.go and returns the routes registered in app.routes(). Routes
+// which use dispatchParam are returned once for each value of the parameter that they dispatch
+// on, and once for the parameter itself unless that falls back to a 404 Not Found.
+func registeredRoutes(t *testing.T) []specRoute {
+	f, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []specRoute
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 3 {
+			return true
+		}
+
+		fn, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (fn.Sel.Name != "HandlerFunc" && fn.Sel.Name != "Handler") {
+			return true
+		}
+		if x, ok := fn.X.(*ast.Ident); !ok || x.Name != "router" {
+			return true
+		}
+
+		method, ok := call.Args[0].(*ast.SelectorExpr)
+		if !ok {
+			t.Fatalf("route method %#v is not an http.Method constant", call.Args[0])
+		}
+
+		path := stringLiteral(t, call.Args[1])
+
+		route := specRoute{Method: strings.ToUpper(strings.TrimPrefix(method.Sel.Name, "Method")), Path: path}
+		routes = append(routes, resolveRoute(t, route, call.Args[2])...)
+
+		return false
+	})
+
+	return routes
+}
+
+// resolveRoute fills in the handler and permission of a route from its handler expression.
+func resolveRoute(t *testing.T, route specRoute, expr ast.Expr) []specRoute {
+	switch expr := expr.(type) {
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok && x.Name == "app" {
+			route.Handler = expr.Sel.Name
+		}
+		return []specRoute{route}
+
+	case *ast.CallExpr:
+		fn, ok := expr.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return []specRoute{route}
+		}
+
+		switch fn.Sel.Name {
+		case "methodNotAllowed":
+			return nil
+		case "requirePermissions":
+			route.Permission = stringLiteral(t, expr.Args[0])
+			return resolveRoute(t, route, expr.Args[1])
+		case "requireActivatedUser", "requireAuthenticatedUser":
+			return resolveRoute(t, route, expr.Args[0])
+		case "dispatchParam":
+			param := stringLiteral(t, expr.Args[0])
+
+			var routes []specRoute
+
+			for _, elt := range expr.Args[1].(*ast.CompositeLit).Elts {
+				kv := elt.(*ast.KeyValueExpr)
+
+				dispatched := route
+				dispatched.Path = strings.Replace(route.Path, ":"+param, stringLiteral(t, kv.Key), 1)
+				routes = append(routes, resolveRoute(t, dispatched, kv.Value)...)
+			}
+
+			return append(routes, resolveRoute(t, route, expr.Args[2])...)
+		}
+	}
+
+	return []specRoute{route}
+}
+
+// stringLiteral returns the value of a string literal expression.
+func stringLiteral(t *testing.T, expr ast.Expr) string {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		t.Fatalf("%#v is not a string literal", expr)
+	}
+
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// TestOpenAPICoversRoutes checks that every route registered in app.routes() has a matching
+// operation in openapi.json, with the same permission, and that openapi.json doesn't describe
+// any routes which don't exist.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]openAPIOperation `json:"paths"`
+	}
+
+	err := json.Unmarshal(openAPISpec, &spec)
+	if err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %s", err)
+	}
+
+	routes := registeredRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("found no routes in routes.go")
+	}
+
+	described := make(map[string]bool)
+
+	for _, route := range routes {
+		path := routeParamRX.ReplaceAllString(route.Path, "{$1}")
+		name := route.Method + " " + path
+
+		described[name] = true
+
+		operation, ok := spec.Paths[path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s is registered in app.routes() but is missing from openapi.json", name)
+			continue
+		}
+
+		if route.Handler != "" && operation.OperationID != strings.TrimSuffix(route.Handler, "Handler") {
+			t.Errorf("%s: got operationId %q; want %q", name, operation.OperationID,
+				strings.TrimSuffix(route.Handler, "Handler"))
+		}
+
+		if operation.Permission != route.Permission {
+			t.Errorf("%s: got x-permission %q; want %q", name, operation.Permission, route.Permission)
+		}
+
+		if len(operation.Responses) == 0 {
+			t.Errorf("%s: has no responses", name)
+		}
+	}
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			name := strings.ToUpper(method) + " " + path
+			if !described[name] {
+				t.Errorf("%s is in openapi.json but isn't registered in app.routes()", name)
+			}
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	app := newTestApp()
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil)
+
+	app.openAPIHandler(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("want Content-Type application/json; got %q", rr.Header().Get("Content-Type"))
+	}
+
+	var spec map[string]interface{}
+
+	err := json.Unmarshal(rr.Body.Bytes(), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec["openapi"] != "3.0.3" {
+		t.Errorf("want openapi version 3.0.3; got %v", spec["openapi"])
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	spec, err := openapi.Load(openAPISpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp()
+	app.openapi = spec
+	app.models.Permissions = data.PermissionModel{DB: permissionsDB(map[int64][]string{
+		1: {"movies:read", "movies:write"},
+	})}
+
+	// The next handler echoes the request body, to check that it's still readable.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(body)
+	})
+
+	activated := &data.User{ID: 1, Activated: true}
+	unpermitted := &data.User{ID: 2, Activated: true}
+
+	tests := []struct {
+		name     string
+		user     *data.User
+		method   string
+		url      string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid body", activated, http.MethodPost, "/v1/movies", `{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["horror"]}`,
+			http.StatusOK, `{"title": "Alien", "year": 1979, "runtime": "117 mins", "genres": ["horror"]}`},
+		{"Invalid body", activated, http.MethodPost, "/v1/movies", `{"title": "Alien", "year": "1979", "runtime": 117, "genres": ["horror"]}`,
+			http.StatusUnprocessableEntity, `"year": "must be an integer"`},
+		{"Invalid query", activated, http.MethodGet, "/v1/movies?release_status=rumoured", ``,
+			http.StatusUnprocessableEntity, `"release_status": "must be one of announced, in_production, released"`},
+		{"Anonymous user", data.AnonymousUser, http.MethodPost, "/v1/movies", `{"year": "1979"}`,
+			http.StatusOK, `{"year": "1979"}`},
+		{"User without permission", unpermitted, http.MethodPost, "/v1/movies", `{"year": "1979"}`,
+			http.StatusOK, `{"year": "1979"}`},
+		{"Public operation", data.AnonymousUser, http.MethodPost, "/v1/tokens/authentication", `{"email": "alice"}`,
+			http.StatusUnprocessableEntity, `"password": "must be provided"`},
+		{"Unknown route", activated, http.MethodPost, "/v1/unknown", `{"year": "1979"}`,
+			http.StatusOK, `{"year": "1979"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			r = app.contextSetUser(r, tt.user)
+
+			app.validateRequests(next).ServeHTTP(rr, r)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rr.Code)
+			}
+
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/jsonpatch"
+)
+
+// The media types of the patch documents accepted by updateMovieHandler, in addition to the
+// plain JSON body read into a moviePatch.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// movieDocument is the JSON representation of a movie that JSON Merge Patch and JSON Patch
+// documents are applied to. Unlike data.Movie, none of the fields are omitted when empty, so
+// that a patch can test, clear or append to any of them.
+type movieDocument struct {
+	Title          string              `json:"title"`
+	Year           int32               `json:"year"`
+	Runtime        data.Runtime        `json:"runtime"`
+	Genres         []string            `json:"genres"`
+	Synopsis       string              `json:"synopsis"`
+	OriginalTitle  string              `json:"original_title"`
+	Languages      []string            `json:"languages"`
+	Certifications data.Certifications `json:"certifications"`
+	ReleaseStatus  string              `json:"release_status"`
+	ReleaseDates   data.ReleaseDates   `json:"release_dates"`
+	IMDbID         string              `json:"imdb_id"`
+	TMDBID         int64               `json:"tmdb_id"`
+}
+
+// patchMediaType returns the media type of the request body if it's one of the patch document
+// types, or an empty string otherwise.
+func patchMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case mergePatchType, jsonPatchType:
+		return mediaType
+	default:
+		return ""
+	}
+}
+
+// applyMoviePatch reads a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) document of the
+// given media type from the request body, and applies it to the movie. A member which is
+// removed from the movie's document (or set to null) is cleared. The returned error wraps
+// jsonpatch.ErrTestFailed or jsonpatch.ErrPathNotFound if the patch couldn't be applied, and
+// describes the problem with the request body otherwise.
+func (app *application) applyMoviePatch(w http.ResponseWriter, r *http.Request, mediaType string, movie *data.Movie) error {
+	maxBytes := 1_048_576
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+	if err != nil {
+		return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+	}
+
+	doc := movieDocument{
+		Title:          movie.Title,
+		Year:           movie.Year,
+		Runtime:        movie.Runtime,
+		Genres:         movie.Genres,
+		Synopsis:       movie.Synopsis,
+		OriginalTitle:  movie.OriginalTitle,
+		Languages:      movie.Languages,
+		Certifications: movie.Certifications,
+		ReleaseStatus:  movie.ReleaseStatus,
+		ReleaseDates:   movie.ReleaseDates,
+		IMDbID:         movie.IMDbID,
+		TMDBID:         movie.TMDBID,
+	}
+
+	// Use empty arrays and objects rather than nulls, so that a JSON Patch can add to them.
+	if doc.Genres == nil {
+		doc.Genres = []string{}
+	}
+	if doc.Languages == nil {
+		doc.Languages = []string{}
+	}
+	if doc.Certifications == nil {
+		doc.Certifications = data.Certifications{}
+	}
+	if doc.ReleaseDates == nil {
+		doc.ReleaseDates = data.ReleaseDates{}
+	}
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case mergePatchType:
+		js, err = jsonpatch.MergePatch(js, patch)
+	default:
+		js, err = jsonpatch.Apply(js, patch)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Decode the patched document with readJSON, so that fields which don't exist and values of
+	// the wrong type are reported in the same way as for a plain JSON body.
+	r.Body = io.NopCloser(bytes.NewReader(js))
+
+	var patched movieDocument
+
+	err = app.readJSON(w, r, &patched)
+	if err != nil {
+		return err
+	}
+
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.Runtime = patched.Runtime
+	movie.Genres = patched.Genres
+	movie.Synopsis = patched.Synopsis
+	movie.OriginalTitle = patched.OriginalTitle
+	movie.Languages = patched.Languages
+	movie.Certifications = patched.Certifications
+	movie.ReleaseStatus = patched.ReleaseStatus
+	movie.ReleaseDates = patched.ReleaseDates
+	movie.IMDbID = patched.IMDbID
+	movie.TMDBID = patched.TMDBID
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// upsertRatingHandler handles the "PUT /v1/movies/:id/rating" endpoint. It creates the
+// authenticated user's rating for a movie, or replaces it if they have already rated the movie,
+// and returns a JSON response of the saved rating.
+func (app *application) upsertRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Rating int32  `json:"rating"`
+		Review string `json:"review"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rating := &data.Rating{
+		MovieID: id,
+		UserID:  app.contextGetUser(r).ID,
+		Rating:  input.Rating,
+		Review:  input.Review,
+	}
+
+	v := validator.New()
+
+	if data.ValidateRating(v, rating); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, err := app.models.Ratings.Upsert(rating)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Send a 201 Created status code if this is the user's first rating of the movie, and a
+	// 200 OK status code if they've edited an existing rating.
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	err = app.writeJSON(w, status, envelope{"rating": rating}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showRatingHandler handles the "GET /v1/movies/:id/rating" endpoint and returns a JSON response
+// of the authenticated user's rating for a movie.
+func (app *application) showRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	rating, err := app.models.Ratings.Get(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"rating": rating}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteRatingHandler handles the "DELETE /v1/movies/:id/rating" endpoint and removes the
+// authenticated user's rating for a movie.
+func (app *application) deleteRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Ratings.Delete(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "rating successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listMovieRatingsHandler handles the "GET /v1/movies/:id/ratings" endpoint and returns a
+// paginated JSON response of the ratings and reviews for a movie.
+func (app *application) listMovieRatingsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ReviewsOnly bool
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	// Only include ratings which have a written review if reviews_only=true.
+	input.ReviewsOnly = app.readBool(qs, "reviews_only", false, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readStrings(qs, "sort", "-updated_at")
+	input.Filters.SortSafeList = []string{"rating", "updated_at", "-rating", "-updated_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Check that the movie exists, so that we can tell the difference between a movie with no
+	// ratings and a movie which doesn't exist.
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	ratings, metadata, err := app.models.Ratings.GetAllForMovie(id, input.ReviewsOnly, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ratings": ratings, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
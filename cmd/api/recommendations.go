@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// readScoredFilters reads the pagination parameters for a list of movies ranked by score. The
+// movies are always sorted by descending score, so there is no sort parameter.
+func (app *application) readScoredFilters(r *http.Request, v *validator.Validator) data.Filters {
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         "-score",
+		SortSafeList: []string{"-score"},
+	}
+
+	data.ValidateFilters(v, filters)
+
+	return filters
+}
+
+// similarMoviesHandler handles the "GET /v1/movies/:id/similar" endpoint and returns a paginated
+// JSON response of the movies most similar to a movie, ranked by genre overlap, year proximity,
+// title similarity and users who liked both movies.
+func (app *application) similarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	filters := app.readScoredFilters(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Check that the movie exists, so that a missing movie isn't mistaken for one with no similar
+	// movies.
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetSimilar(id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localiseTitles(w, r, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.setRuntimeFormat(w, r, movies...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listRecommendationsHandler handles the "GET /v1/users/me/recommendations" endpoint and returns
+// a paginated JSON response of the movies recommended for the authenticated user, based on the
+// movies they have rated or added to their lists.
+func (app *application) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	filters := app.readScoredFilters(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetRecommendations(app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localiseTitles(w, r, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.setRuntimeFormat(w, r, movies...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// releasePromotionBatchSize is the number of movies promoted to released in each transaction.
+const releasePromotionBatchSize = 100
+
+// startReleasePromotion starts a background goroutine which promotes movies to released on
+// their release date, once at startup and then at the configured interval. It stops when the
+// returned function is called, and is tracked by the application's WaitGroup so that graceful
+// shutdown waits for a run in progress. It does nothing if the interval is zero.
+func (app *application) startReleasePromotion() (stop func()) {
+	if app.config.releases.interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+
+	app.background(func() {
+		ticker := time.NewTicker(app.config.releases.interval)
+		defer ticker.Stop()
+
+		for {
+			app.promoteReleases()
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	return func() { close(done) }
+}
+
+// promoteReleases promotes every movie which is due for release today to released, in batches,
+// and logs each promoted movie. Errors are logged rather than returned, so that the next run
+// can try again.
+func (app *application) promoteReleases() {
+	for {
+		movies, err := app.models.Movies.PromoteReleased(time.Now().UTC(), releasePromotionBatchSize)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"task": "release promotion"})
+			return
+		}
+
+		for _, movie := range movies {
+			app.logger.PrintInfo("movie promoted to released", map[string]string{
+				"movie_id": strconv.FormatInt(movie.ID, 10),
+				"title":    movie.Title,
+			})
+		}
+
+		if len(movies) < releasePromotionBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// listMovieRevisionsHandler handles the "GET /v1/movies/:id/revisions" endpoint and returns a
+// paginated JSON response of the revision history for a movie. Revisions are kept after a movie
+// is deleted, so the history of deleted movies can still be browsed.
+func (app *application) listMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	// Default to showing the most recent revisions first.
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readStrings(qs, "sort", "-version")
+	input.Filters.SortSafeList = []string{"version", "created_at", "-version", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.MovieRevisions.GetAll(id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertMovieHandler handles the "POST /v1/movies/:id/revert" endpoint. It restores the movie
+// to the state captured by the requested revision version, and saves it through the usual
+// optimistic concurrency control, so the revert itself is recorded as a new revision.
+func (app *application) revertMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Version int32 `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Version > 0, "version", "must be a positive integer"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// As with updates, check the request's If-Match or X-Expected-Version header against the
+	// current movie before going any further.
+	if !app.checkPreconditions(w, r, movie) {
+		return
+	}
+
+	revision, err := app.models.MovieRevisions.Get(id, input.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("version", "no revision exists for this version")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = revision.Restore(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	genres, err := app.models.Genres.Index()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The restored movie may no longer pass validation (for example, if the validation rules
+	// or genre taxonomy have changed since the revision was made), so check it again before
+	// saving.
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateIMDbID):
+			v.AddError("imdb_id", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateTMDBID):
+			v.AddError("tmdb_id", "a movie with this TMDB ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.loadRelated(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.setRuntimeFormat(w, r, movie)
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
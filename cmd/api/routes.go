@@ -22,23 +22,107 @@ func (app *application) routes() http.Handler {
 	// healthcheck
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 
+	// OpenAPI document handler
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openAPIHandler)
+
 	// application metrics handler
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
 	// Movies handlers. Note, that these movie endpoints use the `requireActivatedUser` middleware.
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermissions("movies:read", app.listMoviesHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermissions("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermissions("movies:read", app.showMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.dispatchParam("id", map[string]http.HandlerFunc{
+		"autocomplete": app.requirePermissions("movies:read", app.autocompleteMoviesHandler),
+	}, app.requirePermissions("movies:read", app.showMovieHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id", app.dispatchParam("id", map[string]http.HandlerFunc{
+		"batch": app.requirePermissions("movies:write", app.batchMoviesHandler),
+	}, app.methodNotAllowed(router)))
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermissions("movies:write", app.updateMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermissions("movies:write", app.deleteMovieHandler))
 
+	// Movie merge handler. Merging deletes a movie, so it needs the movies:admin permission.
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/merge", app.requirePermissions("movies:admin", app.mergeMovieHandler))
+
+	// Similar movies handler
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.requirePermissions("movies:read", app.similarMoviesHandler))
+
+	// Movie revision handlers
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions", app.requirePermissions("movies:read", app.listMovieRevisionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revert", app.requirePermissions("movies:write", app.revertMovieHandler))
+
+	// Movie rating handlers. Any activated user can rate a movie, but one rating per movie.
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/ratings", app.requirePermissions("movies:read", app.listMovieRatingsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/rating", app.requireActivatedUser(app.showRatingHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/rating", app.requireActivatedUser(app.upsertRatingHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/rating", app.requireActivatedUser(app.deleteRatingHandler))
+
+	// Movie credit handlers
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.requirePermissions("movies:read", app.listMovieCreditsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermissions("movies:write", app.createCreditHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/credits/:credit_id", app.requirePermissions("movies:write", app.updateCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requirePermissions("movies:write", app.deleteCreditHandler))
+
+	// Movie alternate title handlers
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/titles", app.requirePermissions("movies:read", app.listMovieTitlesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/titles", app.requirePermissions("movies:write", app.createMovieTitleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/titles/:title_id", app.requirePermissions("movies:write", app.deleteMovieTitleHandler))
+
+	// Movie image handlers
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/images", app.requirePermissions("movies:write", app.uploadMovieImageHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/images/:image_id", app.requirePermissions("movies:write", app.deleteMovieImageHandler))
+
+	// Serve stored images when the storage backend can serve them itself (as the local
+	// filesystem backend does).
+	if h, ok := app.storage.(http.Handler); ok {
+		router.Handler(http.MethodGet, "/v1/images/*filepath", http.StripPrefix("/v1/images", h))
+	}
+
+	// Genres handler
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermissions("movies:read", app.listGenresHandler))
+
+	// People handlers. These share the movies permissions.
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.requirePermissions("movies:read", app.listPeopleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermissions("movies:write", app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.requirePermissions("movies:read", app.showPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermissions("movies:write", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermissions("movies:write", app.deletePersonHandler))
+
+	// Collections handlers. These share the movies permissions.
+	router.HandlerFunc(http.MethodGet, "/v1/collections", app.requirePermissions("movies:read", app.listCollectionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermissions("movies:write", app.createCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.requirePermissions("movies:read", app.showCollectionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermissions("movies:write", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermissions("movies:write", app.deleteCollectionHandler))
+
+	// Lists handlers. Lists can be viewed without authenticating if they are public or unlisted
+	// (with the share token), but only activated users can create and manage their own lists.
+	router.HandlerFunc(http.MethodGet, "/v1/lists", app.requireActivatedUser(app.listListsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists", app.requireActivatedUser(app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.showListHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id", app.requireActivatedUser(app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id", app.requireActivatedUser(app.deleteListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id/entries", app.listListEntriesHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/lists/:id/entries", app.requireActivatedUser(app.addListEntryHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id/entries/:movie_id", app.requireActivatedUser(app.updateListEntryHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id/entries/:movie_id", app.requireActivatedUser(app.removeListEntryHandler))
+
 	// Users handlers
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/recommendations", app.requirePermissions("movies:read", app.listRecommendationsHandler))
 
 	// Tokens handlers
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
+	// GraphQL handler, which checks permissions field by field.
+	router.HandlerFunc(http.MethodPost, "/v1/graphql", app.graphqlHandler)
+
+	// Validate requests against the OpenAPI document before they reach the router, if enabled.
+	var handler http.Handler = router
+	if app.openapi != nil {
+		handler = app.validateRequests(router)
+	}
+
 	// Wrap the router with the panic recovery middleware and rate limit middleware.
-	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(handler)))))
 }
@@ -27,6 +27,9 @@ func (app *application) serve() error {
 	// by the graceful Shutdown() function.
 	shutdownError := make(chan error)
 
+	// Start the background job which promotes movies to released on their release date.
+	stopReleasePromotion := app.startReleasePromotion()
+
 	// Start a background goroutine.
 	go func() {
 		// Create a quit channel which carries os.Signal values. Use buffered
@@ -59,6 +62,9 @@ func (app *application) serve() error {
 			shutdownError <- err
 		}
 
+		// Stop the release promotion job, so that it doesn't hold up the wait below.
+		stopReleasePromotion()
+
 		// Log a message to say that we're waiting for any background goroutines to complete
 		// their tasks.
 		app.logger.PrintInfo("completing background tasks", map[string]string{
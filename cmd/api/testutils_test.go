@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -22,6 +27,28 @@ func newTestApp() *application {
 	return app
 }
 
+// newTestDB opens the database named by the GREENLIGHT_TEST_DB_DSN environment variable, which
+// must have every migration applied, and skips the test if it isn't set.
+func newTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("GREENLIGHT_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return db
+}
+
 // Create a newTestServer helper which initializes and returns a new instance of our
 // custom testServer type.
 func newTestServer(h http.Handler) *testServer {
@@ -58,3 +85,48 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byt
 
 	return rs.StatusCode, rs.Header, body
 }
+
+// permissionsDB returns a database which answers every query with the permission codes of the
+// user whose ID is the first argument, so that permission checks can run without Postgres.
+func permissionsDB(permissions map[int64][]string) *sql.DB {
+	return sql.OpenDB(permissionsConnector{permissions})
+}
+
+type permissionsConnector struct {
+	permissions map[int64][]string
+}
+
+func (c permissionsConnector) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c permissionsConnector) Driver() driver.Driver                        { return nil }
+func (c permissionsConnector) Prepare(string) (driver.Stmt, error)          { return c, nil }
+func (c permissionsConnector) Close() error                                 { return nil }
+func (c permissionsConnector) NumInput() int                                { return 1 }
+
+func (c permissionsConnector) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c permissionsConnector) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("statements are not supported")
+}
+
+func (c permissionsConnector) Query(args []driver.Value) (driver.Rows, error) {
+	userID, _ := args[0].(int64)
+	return &permissionsRows{codes: c.permissions[userID]}, nil
+}
+
+type permissionsRows struct {
+	codes []string
+}
+
+func (r *permissionsRows) Columns() []string { return []string{"code"} }
+func (r *permissionsRows) Close() error      { return nil }
+
+func (r *permissionsRows) Next(dest []driver.Value) error {
+	if len(r.codes) == 0 {
+		return io.EOF
+	}
+
+	dest[0], r.codes = r.codes[0], r.codes[1:]
+	return nil
+}
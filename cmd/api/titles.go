@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/data"
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// listMovieTitlesHandler handles the "GET /v1/movies/:id/titles" endpoint and returns a JSON
+// response of the alternate titles of a movie.
+func (app *application) listMovieTitlesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	titles, err := app.models.MovieTitles.GetAllForMovies(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Make sure that a movie without any alternate titles gives an empty JSON array rather than
+	// null.
+	movieTitles := titles[id]
+	if movieTitles == nil {
+		movieTitles = []*data.MovieTitle{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"titles": movieTitles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createMovieTitleHandler handles the "POST /v1/movies/:id/titles" endpoint, which adds an
+// alternate title for a language (and optionally a region) to a movie, and returns a JSON
+// response of the new title.
+func (app *application) createMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Language string `json:"language"`
+		Region   string `json:"region"`
+		Title    string `json:"title"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	title := &data.MovieTitle{
+		MovieID:  id,
+		Language: input.Language,
+		Region:   input.Region,
+		Title:    input.Title,
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieTitle(v, title); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.MovieTitles.Insert(title)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateMovieTitle):
+			v.AddError("language", "the movie already has a title for this language and region")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/titles/%d", id, title.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"title": title}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteMovieTitleHandler handles the "DELETE /v1/movies/:id/titles/:title_id" endpoint.
+func (app *application) deleteMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	titleID, err := app.readNamedIDParam(r, "title_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.MovieTitles.Delete(id, titleID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "title successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// parseAcceptLanguage returns the language tags listed in an Accept-Language header, in order of
+// preference. Tags with a quality of zero, and the "*" wildcard, are left out.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weighted
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				q, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > 0 {
+			tags = append(tags, weighted{tag, quality})
+		}
+	}
+
+	// Tags with the same quality keep the order they were listed in.
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	result := make([]string, len(tags))
+	for i, tag := range tags {
+		result[i] = tag.tag
+	}
+
+	return result
+}
+
+// wantLocalisedTitles reports whether a sparse fieldset includes any of the fields which are set
+// by localiseTitles, so that the titles need localising.
+func wantLocalisedTitles(fields []string) bool {
+	return wantField(fields, "title") || wantField(fields, "title_locale") || wantField(fields, "original_title")
+}
+
+// localiseTitles replaces the title of each of the provided movies with the alternate title that
+// best matches the request's Accept-Language header, if it has one, and sets the movie's
+// TitleLocale. The original title is always available in original_title, which falls back to
+// the movie's main title when it has no separate original title.
+func (app *application) localiseTitles(w http.ResponseWriter, r *http.Request, movies ...*data.Movie) error {
+	// The Accept-Language header can change the response, so caches must take it into account.
+	w.Header().Add("Vary", "Accept-Language")
+
+	for _, movie := range movies {
+		if movie.OriginalTitle == "" {
+			movie.OriginalTitle = movie.Title
+		}
+	}
+
+	tags := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if len(tags) == 0 || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	titles, err := app.models.MovieTitles.GetAllForMovies(ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if title := data.LocalisedTitle(titles[movie.ID], tags); title != nil {
+			movie.Title = title.Title
+			movie.TitleLocale = title.Locale()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseAcceptLanguage tests that the language tags of an Accept-Language header are returned
+// in order of preference.
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{"Empty", "", []string{}},
+		{"Single", "fr-CA", []string{"fr-CA"}},
+		{"Qualities", "en;q=0.5, fr-CA, fr;q=0.8", []string{"fr-CA", "fr", "en"}},
+		{"Equal qualities keep their order", "de, es", []string{"de", "es"}},
+		{"Wildcard and zero quality", "ja, *;q=0.1, en;q=0", []string{"ja"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseAcceptLanguage(tt.header)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAcceptLanguage(%q) = %q; want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWantLocalisedTitles tests that titles are localised whenever a field which depends on them
+// is in the sparse fieldset.
+func TestWantLocalisedTitles(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		want   bool
+	}{
+		{"No sparse fieldset", nil, true},
+		{"Title", []string{"id", "title"}, true},
+		{"Title locale without title", []string{"id", "title_locale"}, true},
+		{"Original title", []string{"original_title"}, true},
+		{"No title fields", []string{"id", "year"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wantLocalisedTitles(tt.fields); got != tt.want {
+				t.Errorf("wantLocalisedTitles(%q) = %t; want %t", tt.fields, got, tt.want)
+			}
+		})
+	}
+}
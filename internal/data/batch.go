@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The actions which can be performed by a BatchOperation.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchActions holds the actions which can be performed by a BatchOperation.
+var BatchActions = []string{BatchCreate, BatchUpdate, BatchDelete}
+
+// ErrBatchAborted is the error given for the operations in an atomic batch which weren't
+// applied because another operation in the batch failed.
+var ErrBatchAborted = errors.New("not applied because another operation in the batch failed")
+
+// BatchOperation is a single write in a batch passed to MovieModel.Batch. For a create, Movie
+// holds the new movie. For an update, Movie holds the updated movie, with its Version set to
+// the version that the update was based on. For a delete, ID identifies the movie, and Version
+// is the version that it is expected to be at, or 0 to skip the version check.
+type BatchOperation struct {
+	Action  string
+	ID      int64
+	Version int32
+	Movie   *Movie
+}
+
+// Batch applies the operations in order, in a single transaction, with their revisions
+// attributed to the provided user ID. It returns the error for each operation, which is nil if
+// the operation succeeded. The errors which an operation can fail with are ErrRecordNotFound,
+// ErrEditConflict, ErrDuplicateIMDbID and ErrDuplicateTMDBID; any other error fails the whole
+// batch and is returned as the second return value.
+//
+// The batch is atomic: if any operation fails, then none of them are applied, and the other
+// operations are given ErrBatchAborted. If partial is true, the failed operations are skipped
+// instead and the others are still applied.
+func (m MovieModel) Batch(ops []*BatchOperation, userID int64, partial bool) ([]error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	errs := make([]error, len(ops))
+
+	for i, op := range ops {
+		// In partial mode, run each operation in a savepoint, so that a failed operation can be
+		// rolled back on its own without aborting the transaction.
+		if partial {
+			_, err = tx.ExecContext(ctx, `SAVEPOINT batch_operation`)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch op.Action {
+		case BatchCreate:
+			err = m.insertTx(ctx, tx, op.Movie, userID)
+		case BatchUpdate:
+			err = m.updateTx(ctx, tx, op.Movie, userID)
+		case BatchDelete:
+			err = m.deleteTx(ctx, tx, op.ID, op.Version, userID)
+		default:
+			return nil, fmt.Errorf("unknown batch action %q", op.Action)
+		}
+
+		switch {
+		case err == nil:
+			if partial {
+				_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_operation`)
+				if err != nil {
+					return nil, err
+				}
+			}
+		case errors.Is(err, ErrRecordNotFound), errors.Is(err, ErrEditConflict),
+			errors.Is(err, ErrDuplicateIMDbID), errors.Is(err, ErrDuplicateTMDBID):
+			if !partial {
+				for j := range errs {
+					errs[j] = ErrBatchAborted
+				}
+				errs[i] = err
+
+				return errs, nil
+			}
+
+			errs[i] = err
+
+			_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_operation`)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,405 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ErrCollectionMovieNotFound is returned when a collection is given a movie ID which doesn't
+// exist.
+var ErrCollectionMovieNotFound = errors.New("collection movie not found")
+
+// Collection type whose fields describe a group of movies in an explicit order, such as a
+// franchise in release order. Movies is left out when listing collections.
+type Collection struct {
+	ID          int64              `json:"id"`
+	CreatedAt   time.Time          `json:"-"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Movies      []*CollectionMovie `json:"movies,omitempty"`
+	Version     int32              `json:"version"`
+}
+
+// CollectionMovie type whose fields describe a single movie in a collection. Position is 1-based.
+type CollectionMovie struct {
+	MovieID  int64  `json:"movie_id"`
+	Position int32  `json:"position"`
+	Title    string `json:"title"`
+	Year     int32  `json:"year,omitempty"`
+}
+
+// MovieCollection type whose fields describe a collection that a movie belongs to, and the
+// movie's position in it. It's embedded in the movie's JSON output.
+type MovieCollection struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int32  `json:"position"`
+}
+
+// CollectionModel struct wraps a sql.DB connection pool and allows us to work with the Collection
+// struct type and the collections and collection_movies tables in our database.
+type CollectionModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// Insert inserts a new record in the collections table, with the provided movie IDs as its
+// movies in order.
+func (m CollectionModel) Insert(collection *Collection, movieIDs []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	query := `
+		INSERT INTO collections (name, description)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version
+		`
+
+	args := []interface{}{collection.Name, collection.Description}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+	if err != nil {
+		return err
+	}
+
+	collection.Movies, err = m.setMovies(ctx, tx, collection.ID, movieIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Get fetches a record from the collections table, along with its movies in order.
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, description, version
+		FROM collections
+		WHERE id = $1
+		`
+
+	var collection Collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Name,
+		&collection.Description,
+		&collection.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	collection.Movies, err = m.getMovies(ctx, m.DB, collection.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// Update updates a specific record in the collections table, using the version number for
+// optimistic concurrency control. If movieIDs isn't nil, then it replaces the collection's movies
+// in order.
+func (m CollectionModel) Update(collection *Collection, movieIDs []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	query := `
+		UPDATE collections
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version
+		`
+
+	args := []interface{}{collection.Name, collection.Description, collection.ID, collection.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if movieIDs != nil {
+		collection.Movies, err = m.setMovies(ctx, tx, collection.ID, movieIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete deletes a specific record in the collections table. The movies themselves are kept.
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM collections
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll returns a paginated list of collections, optionally filtered by name. The movies of
+// each collection aren't included.
+func (m CollectionModel) GetAll(name string, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, description, version
+		FROM collections
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s, id ASC
+		LIMIT $2 OFFSET $3`,
+		filters.orderBy(""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.Name,
+			&collection.Description,
+			&collection.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return collections, metadata, nil
+}
+
+// GetAllForMovies returns the collections that each of the provided movie IDs belongs to in a
+// single query, keyed by movie ID. This lets us embed collections in a page of movies without
+// making a query per movie.
+func (m CollectionModel) GetAllForMovies(movieIDs ...int64) (map[int64][]*MovieCollection, error) {
+	collections := make(map[int64][]*MovieCollection)
+
+	if len(movieIDs) == 0 {
+		return collections, nil
+	}
+
+	// Positions are numbered from 1 at read time, in the same way as in getMovies.
+	query := `
+		SELECT ranked.movie_id, collections.id, collections.name, ranked.position
+		FROM (
+			SELECT collection_id, movie_id,
+				ROW_NUMBER() OVER (PARTITION BY collection_id ORDER BY position, movie_id) AS position
+			FROM collection_movies
+			WHERE collection_id IN (SELECT collection_id FROM collection_movies WHERE movie_id = ANY($1))
+			) AS ranked
+			INNER JOIN collections ON collections.id = ranked.collection_id
+		WHERE ranked.movie_id = ANY($1)
+		ORDER BY ranked.movie_id, collections.name, collections.id
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			movieID    int64
+			collection MovieCollection
+		)
+
+		err := rows.Scan(&movieID, &collection.ID, &collection.Name, &collection.Position)
+		if err != nil {
+			return nil, err
+		}
+
+		collections[movieID] = append(collections[movieID], &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getMovies returns the movies in a collection, in order. Positions are numbered from 1
+// at read time, so any gaps left behind by deleted movies are never visible to clients.
+func (m CollectionModel) getMovies(ctx context.Context, db queryer, collectionID int64) ([]*CollectionMovie, error) {
+	query := `
+		SELECT collection_movies.movie_id,
+			ROW_NUMBER() OVER (ORDER BY collection_movies.position, collection_movies.movie_id),
+			movies.title, movies.year
+		FROM collection_movies
+			INNER JOIN movies ON movies.id = collection_movies.movie_id
+		WHERE collection_movies.collection_id = $1
+		ORDER BY collection_movies.position, collection_movies.movie_id
+		`
+
+	rows, err := db.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	movies := []*CollectionMovie{}
+
+	for rows.Next() {
+		var movie CollectionMovie
+
+		err := rows.Scan(&movie.MovieID, &movie.Position, &movie.Title, &movie.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// setMovies replaces the movies in a collection with the provided movie IDs, in order,
+// as part of the provided transaction, and returns the collection's new movies. It returns
+// ErrCollectionMovieNotFound if any of the movies don't exist.
+func (m CollectionModel) setMovies(ctx context.Context, tx *sql.Tx, collectionID int64, movieIDs []int64) ([]*CollectionMovie, error) {
+	_, err := tx.ExecContext(ctx, `DELETE FROM collection_movies WHERE collection_id = $1`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO collection_movies (collection_id, movie_id, position)
+		SELECT $1, ids.movie_id, ids.position
+		FROM unnest($2::BIGINT[]) WITH ORDINALITY AS ids(movie_id, position)
+		`
+
+	_, err = tx.ExecContext(ctx, query, collectionID, pq.Array(movieIDs))
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "collection_movies" violates foreign key constraint "collection_movies_movie_id_fkey"`:
+			return nil, ErrCollectionMovieNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.getMovies(ctx, tx, collectionID)
+}
+
+// ValidateCollection runs validation checks on the Collection type, and on the IDs of the movies
+// to put in it (which may be nil if they aren't changing).
+func ValidateCollection(v *validator.Validator, collection *Collection, movieIDs []int64) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(len(collection.Description) <= 10_000, "description", "must not be more than 10000 bytes long")
+
+	v.Check(len(movieIDs) <= 500, "movie_ids", "must not contain more than 500 movies")
+
+	seen := make(map[int64]bool, len(movieIDs))
+	for _, id := range movieIDs {
+		v.Check(id > 0, "movie_ids", "must only contain positive integers")
+		v.Check(!seen[id], "movie_ids", "must not contain duplicate values")
+		seen[id] = true
+	}
+}
@@ -0,0 +1,253 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// CreditRoles holds the roles that a person can be credited with on a movie.
+var CreditRoles = []string{"director", "writer", "producer", "actor", "composer", "cinematographer",
+	"editor", "crew"}
+
+var (
+	// ErrDuplicateCredit is returned when a person already has the same credit on a movie.
+	ErrDuplicateCredit = errors.New("duplicate credit")
+)
+
+// Credit type whose fields describe a person's credit on a movie. The Character field is only
+// used for actors, and BillingOrder controls the order in which credits are listed.
+type Credit struct {
+	ID           int64  `json:"id"`
+	MovieID      int64  `json:"movie_id"`
+	PersonID     int64  `json:"person_id"`
+	PersonName   string `json:"person_name"`
+	Role         string `json:"role"`
+	Character    string `json:"character,omitempty"`
+	BillingOrder int32  `json:"billing_order"`
+}
+
+// CreditModel struct wraps a sql.DB connection pool and allows us to work with the Credit struct
+// type and the credits table in our database.
+type CreditModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// creditError converts constraint violations on the credits table into our own errors.
+func creditError(err error) error {
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "credits_movie_id_person_id_role_character_key"`:
+		return ErrDuplicateCredit
+	case err.Error() == `pq: insert or update on table "credits" violates foreign key constraint "credits_person_id_fkey"`,
+		err.Error() == `pq: insert or update on table "credits" violates foreign key constraint "credits_movie_id_fkey"`:
+		return ErrRecordNotFound
+	default:
+		return err
+	}
+}
+
+// Insert inserts a new record in the credits table. It returns ErrRecordNotFound if either the
+// movie or the person doesn't exist.
+func (m CreditModel) Insert(credit *Credit) error {
+	query := `
+		WITH inserted AS (
+			INSERT INTO credits (movie_id, person_id, role, character, billing_order)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, person_id
+		)
+		SELECT inserted.id, people.name
+		FROM inserted
+			INNER JOIN people ON people.id = inserted.person_id
+		`
+
+	args := []interface{}{credit.MovieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.PersonName)
+	if err != nil {
+		return creditError(err)
+	}
+
+	return nil
+}
+
+// Get fetches a specific credit for a movie.
+func (m CreditModel) Get(movieID, id int64) (*Credit, error) {
+	if movieID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT credits.id, credits.movie_id, credits.person_id, people.name, credits.role,
+			credits.character, credits.billing_order
+		FROM credits
+			INNER JOIN people ON people.id = credits.person_id
+		WHERE credits.movie_id = $1 AND credits.id = $2
+		`
+
+	var credit Credit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, id).Scan(
+		&credit.ID,
+		&credit.MovieID,
+		&credit.PersonID,
+		&credit.PersonName,
+		&credit.Role,
+		&credit.Character,
+		&credit.BillingOrder,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &credit, nil
+}
+
+// Update updates the role, character and billing order of a specific credit.
+func (m CreditModel) Update(credit *Credit) error {
+	query := `
+		UPDATE credits
+		SET role = $1, character = $2, billing_order = $3
+		WHERE movie_id = $4 AND id = $5
+		`
+
+	args := []interface{}{credit.Role, credit.Character, credit.BillingOrder, credit.MovieID, credit.ID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return creditError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a specific credit for a movie.
+func (m CreditModel) Delete(movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM credits
+		WHERE movie_id = $1 AND id = $2
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForMovies returns the credits for each of the provided movie IDs in a single query, in
+// billing order, keyed by movie ID. This lets us embed credits in a page of movies without
+// making a query per movie.
+func (m CreditModel) GetAllForMovies(movieIDs ...int64) (map[int64][]*Credit, error) {
+	credits := make(map[int64][]*Credit)
+
+	if len(movieIDs) == 0 {
+		return credits, nil
+	}
+
+	query := `
+		SELECT credits.id, credits.movie_id, credits.person_id, people.name, credits.role,
+			credits.character, credits.billing_order
+		FROM credits
+			INNER JOIN people ON people.id = credits.person_id
+		WHERE credits.movie_id = ANY($1)
+		ORDER BY credits.movie_id, credits.billing_order, credits.id
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.PersonName,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits[credit.MovieID] = append(credits[credit.MovieID], &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// ValidateCredit runs validation checks on the Credit type.
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be a positive integer")
+
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(validator.In(credit.Role, CreditRoles...), "role", "invalid role")
+
+	v.Check(credit.Character == "" || credit.Role == "actor", "character", "must only be provided for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded, or its signature
+// doesn't match because it has been tampered with or was signed with a different secret.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list of movies for keyset pagination. It holds the sort the list
+// was in along with the value of each sort key and the ID of the last movie on the previous
+// page, so that the next page can carry on from that movie without using OFFSET.
+type Cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     int64    `json:"id"`
+	// Query is a fingerprint of the filters the list was requested with, so that a cursor can't
+	// be used to page through a different list.
+	Query string `json:"q"`
+	// Fuzzy holds the fuzzy match text when the list is the fuzzy fallback for a search which
+	// found nothing, so that later pages carry on with the fallback.
+	Fuzzy string `json:"f,omitempty"`
+}
+
+// Encode returns the cursor as an opaque, URL-safe string signed with HMAC-SHA256 using the
+// provided secret. The contents aren't encrypted, but any change to them is detected by
+// DecodeCursor.
+func (c Cursor) Encode(secret []byte) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signCursor(secret, encoded)), nil
+}
+
+// DecodeCursor checks the signature of a cursor string produced by Cursor.Encode and returns the
+// decoded cursor. It returns ErrInvalidCursor if the string is malformed or the signature
+// doesn't match.
+func DecodeCursor(secret []byte, s string) (*Cursor, error) {
+	encoded, signature, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signCursor(secret, encoded)) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+
+	err = json.Unmarshal(payload, &c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// signCursor returns the HMAC-SHA256 of an encoded cursor payload.
+func signCursor(secret []byte, encoded string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// sortValue returns the value of the movie's sort column as text, in a form that PostgreSQL can
+// compare with the column again when the text is passed back as a placeholder parameter.
+func (movie *Movie) sortValue(column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		return strconv.FormatInt(int64(movie.Runtime), 10)
+	case "average_rating":
+		return strconv.FormatFloat(movie.AverageRating, 'f', -1, 64)
+	case "rating_count":
+		return strconv.FormatInt(int64(movie.RatingCount), 10)
+	case "relevance":
+		// Relevance is a REAL, so format it with float32 precision to get back exactly the same
+		// value that was selected.
+		return strconv.FormatFloat(movie.Relevance, 'g', -1, 32)
+	default:
+		return strconv.FormatInt(movie.ID, 10)
+	}
+}
+
+// GetAllAfter returns a page of movies using keyset pagination, starting after the position
+// marked by the cursor, or from the first movie if the cursor is nil. The cursor must be for the
+// same sort as the filters. Unlike GetAll, later pages are as quick to fetch as the first, and
+// movies inserted while a client is paging don't shift the results.
+//
+// It also returns a cursor for the page after this one, which is nil if this is the last page.
+// The total number of matching movies is only counted if includeTotal is true.
+func (m MovieModel) GetAllAfter(mf MovieFilters, filters Filters, after *Cursor, includeTotal bool) ([]*Movie, Metadata, *Cursor, error) {
+	keys := filters.sortKeys()
+	relevance := searchRank(1) + ` + ` + fuzzyRank(10)
+	columns, scanDest := movieSelection(filters)
+
+	args := mf.args()
+
+	keyset := "TRUE"
+	if after != nil {
+		if len(after.Values) != len(keys) {
+			return nil, Metadata{}, nil, ErrInvalidCursor
+		}
+
+		keyset = keysetCondition(filters, relevance, len(args)+1)
+
+		for _, value := range after.Values {
+			args = append(args, value)
+		}
+		args = append(args, after.ID)
+	}
+
+	// Fetch one more movie than the page size, to find out whether there is a next page.
+	args = append(args, filters.limit()+1)
+
+	query := fmt.Sprintf(`
+		SELECT `+columns+`, `+relevance+` AS relevance
+		FROM movies
+		WHERE `+movieFilterConditions+`
+		AND `+genresCondition+`
+		AND `+decadeCondition+`
+		AND `+runtimeCondition+`
+		AND %s
+		ORDER BY %s, id ASC
+		LIMIT $%d`,
+		keyset, filters.orderBy(""), len(args))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(append(scanDest(&movie), &movie.Relevance)...)
+		if err != nil {
+			return nil, Metadata{}, nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, nil, err
+	}
+
+	var next *Cursor
+	if len(movies) > filters.limit() {
+		movies = movies[:filters.limit()]
+
+		last := movies[len(movies)-1]
+		next = &Cursor{Sort: filters.Sort, ID: last.ID}
+
+		for _, key := range keys {
+			next.Values = append(next.Values, last.sortValue(filters.keyColumn(key)))
+		}
+	}
+
+	if mf.Search != "" {
+		err = m.highlight(ctx, mf.Search, movies)
+		if err != nil {
+			return nil, Metadata{}, nil, err
+		}
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+
+	if includeTotal {
+		metadata.TotalRecords, err = m.Count(mf)
+		if err != nil {
+			return nil, Metadata{}, nil, err
+		}
+	}
+
+	return movies, metadata, next, nil
+}
+
+// keysetCondition returns an SQL condition which matches the movies that come after a cursor in
+// the order given by the sort keys, followed by ascending ID. The cursor's sort values are in
+// the placeholder parameters starting at $n, followed by its ID. For the sort "-year,title" this
+// is equivalent to:
+//
+//	year < $n OR (year = $n AND title > $n+1) OR (year = $n AND title = $n+1 AND id > $n+2)
+//
+// The relevance can't be referred to by its alias in a WHERE clause, so the relevance
+// expression is used in its place.
+func keysetCondition(filters Filters, relevance string, n int) string {
+	var (
+		branches []string
+		equal    []string
+	)
+
+	for i, key := range filters.sortKeys() {
+		expr := filters.keyColumn(key)
+		if expr == "relevance" {
+			expr = "(" + relevance + ")"
+		}
+
+		operator := ">"
+		if keyDirection(key) == "DESC" {
+			operator = "<"
+		}
+
+		branch := append(equal[:len(equal):len(equal)], fmt.Sprintf("%s %s $%d", expr, operator, n+i))
+		branches = append(branches, "("+strings.Join(branch, " AND ")+")")
+
+		equal = append(equal, fmt.Sprintf("%s = $%d", expr, n+i))
+	}
+
+	last := append(equal, fmt.Sprintf("id > $%d", n+len(equal)))
+	branches = append(branches, "("+strings.Join(last, " AND ")+")")
+
+	return "(" + strings.Join(branches, "\n\t\tOR ") + ")"
+}
+
+// Count returns the number of movies matching the filters.
+func (m MovieModel) Count(mf MovieFilters) (int, error) {
+	query := `
+		SELECT count(*)
+		FROM movies
+		WHERE ` + movieFilterConditions + `
+		AND ` + genresCondition + `
+		AND ` + decadeCondition + `
+		AND ` + runtimeCondition
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var total int
+
+	err := m.DB.QueryRowContext(ctx, query, mf.args()...).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
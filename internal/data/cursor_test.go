@@ -0,0 +1,64 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	secret := []byte("secret")
+	cursor := Cursor{Sort: "-title", Values: []string{"The Matrix"}, ID: 42, Query: "abc"}
+
+	encoded, err := cursor.Encode(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeCursor(secret, encoded)
+	if err != nil {
+		t.Fatalf("DecodeCursor(%q) returned error %v", encoded, err)
+	}
+	if !reflect.DeepEqual(*decoded, cursor) {
+		t.Errorf("DecodeCursor(%q) = %+v; want %+v", encoded, *decoded, cursor)
+	}
+
+	payload, signature, _ := strings.Cut(encoded, ".")
+	other, _ := Cursor{Sort: "-title", Values: []string{"Alien"}, ID: 42, Query: "abc"}.Encode(secret)
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name   string
+		secret []byte
+		input  string
+	}{
+		{"Wrong secret", []byte("other"), encoded},
+		{"Tampered payload", secret, otherPayload + "." + signature},
+		{"Missing signature", secret, payload},
+		{"Malformed", secret, "not a cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.secret, tt.input)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) returned error %v; want %v", tt.input, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	filters := Filters{
+		Sort:         "-year,title",
+		SortSafeList: []string{"id", "title", "year", "-id", "-title", "-year"},
+	}
+
+	want := "((year < $21)\n\t\tOR (year = $21 AND title > $22)\n\t\tOR (year = $21 AND title = $22 AND id > $23))"
+
+	got := keysetCondition(filters, "", 21)
+	if got != want {
+		t.Errorf("keysetCondition() = %q; want %q", got, want)
+	}
+}
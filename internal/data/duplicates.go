@@ -0,0 +1,257 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrMergeSelf is returned when a movie is merged into itself.
+var ErrMergeSelf = errors.New("cannot merge a movie into itself")
+
+// DuplicateCandidate describes an existing movie which looks like a duplicate of a new one.
+// Exact candidates have the same normalised title and year; the others have a similar title and
+// a year within one of the new movie's.
+type DuplicateCandidate struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Year       int32   `json:"year"`
+	Similarity float64 `json:"similarity"`
+	Exact      bool    `json:"exact"`
+}
+
+// FindDuplicates returns up to 10 existing movies which look like duplicates of a movie with the
+// given title and year, exact matches first and then by descending title similarity.
+func (m MovieModel) FindDuplicates(title string, year int32) ([]*DuplicateCandidate, error) {
+	query := `
+		SELECT id, title, year,
+			similarity(normalise_title(title), normalise_title($1)),
+			normalise_title(title) = normalise_title($1) AND year = $2
+		FROM movies
+		WHERE (normalise_title(title) % normalise_title($1) OR normalise_title(title) = normalise_title($1))
+		AND year BETWEEN $2 - 1 AND $2 + 1
+		ORDER BY 5 DESC, 4 DESC, id ASC
+		LIMIT 10
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, title, year)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	candidates := []*DuplicateCandidate{}
+
+	for rows.Next() {
+		var candidate DuplicateCandidate
+
+		err := rows.Scan(&candidate.ID, &candidate.Title, &candidate.Year, &candidate.Similarity, &candidate.Exact)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, &candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// GetRedirect returns the ID of the movie that a merged movie ID now redirects to. It returns
+// ErrRecordNotFound if the ID has never been merged.
+func (m MovieModel) GetRedirect(oldID int64) (int64, error) {
+	if oldID < 1 {
+		return 0, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT movie_id
+		FROM movie_redirects
+		WHERE old_id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query, oldID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// Merge folds the source movie into the target movie and then deletes the source. Ratings, list
+// entries, collection memberships, credits, alternate titles and images are moved across unless
+// the target already has an equivalent one (e.g. the same user has rated both movies), in which
+// case the target's is kept. Lookups of the source ID, and of any IDs previously merged into it,
+// are redirected to the target. The target's version is incremented, so that clients holding the
+// old version see an edit conflict, and revisions attributed to the provided user ID are recorded
+// for both the updated target and the deleted source.
+//
+// It returns ErrRecordNotFound if either movie doesn't exist.
+func (m MovieModel) Merge(sourceID, targetID, userID int64) error {
+	if sourceID == targetID {
+		return ErrMergeSelf
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	// Lock both movies, always in ID order, so that two concurrent merges of the same pair of
+	// movies can't deadlock.
+	var source, target *Movie
+	for _, id := range []int64{minID(sourceID, targetID), maxID(sourceID, targetID)} {
+		movie, err := m.getForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if id == sourceID {
+			source = movie
+		} else {
+			target = movie
+		}
+	}
+
+	queries := []string{
+		// Copy ratings rather than updating their movie ID, so that the aggregate trigger on
+		// the ratings table keeps the rating counts of both movies correct.
+		`INSERT INTO ratings (user_id, movie_id, rating, review, created_at, updated_at, version)
+		SELECT user_id, $2, rating, review, created_at, updated_at, version
+		FROM ratings
+		WHERE movie_id = $1
+		ON CONFLICT (user_id, movie_id) DO NOTHING`,
+
+		// Keep the source's position in lists which don't already contain the target.
+		`UPDATE list_entries
+		SET movie_id = $2
+		WHERE movie_id = $1
+		AND list_id NOT IN (SELECT list_id FROM list_entries WHERE movie_id = $2)`,
+
+		// Likewise keep the source's position in collections which don't already contain the
+		// target.
+		`UPDATE collection_movies
+		SET movie_id = $2
+		WHERE movie_id = $1
+		AND collection_id NOT IN (SELECT collection_id FROM collection_movies WHERE movie_id = $2)`,
+
+		`UPDATE credits
+		SET movie_id = $2
+		WHERE movie_id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM credits AS existing
+			WHERE existing.movie_id = $2 AND existing.person_id = credits.person_id
+			AND existing.role = credits.role AND existing.character = credits.character
+			)`,
+
+		`UPDATE movie_titles
+		SET movie_id = $2
+		WHERE movie_id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM movie_titles AS existing
+			WHERE existing.movie_id = $2 AND existing.language = movie_titles.language
+			AND existing.region = movie_titles.region
+			)`,
+
+		`UPDATE movie_images
+		SET movie_id = $2
+		WHERE movie_id = $1`,
+
+		`UPDATE movie_redirects
+		SET movie_id = $2
+		WHERE movie_id = $1`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.ExecContext(ctx, query, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO movie_redirects (old_id, movie_id, user_id)
+		VALUES ($1, $2, $3)
+		`
+
+	_, err = tx.ExecContext(ctx, query, sourceID, targetID, sql.NullInt64{Int64: userID, Valid: userID > 0})
+	if err != nil {
+		return err
+	}
+
+	// Delete the source movie. Anything which wasn't moved across (such as duplicate ratings)
+	// is removed along with it.
+	_, err = tx.ExecContext(ctx, `DELETE FROM movies WHERE id = $1`, sourceID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, RevisionDelete, userID, source, nil)
+	if err != nil {
+		return err
+	}
+
+	// The target's own fields are unchanged, but everything merged into it is new, so bump its
+	// version and record that in its history.
+	merged := *target
+
+	query = `
+		UPDATE movies
+		SET version = version + 1
+		WHERE id = $1
+		RETURNING version
+		`
+
+	err = tx.QueryRowContext(ctx, query, targetID).Scan(&merged.Version)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, RevisionUpdate, userID, target, &merged)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// minID returns the smaller of two IDs.
+func minID(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxID returns the larger of two IDs.
+func maxID(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MovieFacets holds the facets that can be counted by MovieModel.Facets.
+var MovieFacets = []string{"genres", "decade", "runtime"}
+
+// RuntimeBuckets holds the runtime ranges, in minutes, that movies are grouped into for the
+// runtime facet and filter.
+var RuntimeBuckets = []string{"0-89", "90-119", "120-149", "150+"}
+
+// runtimeBucketSQL is an SQL expression for the RuntimeBuckets value of a movie.
+const runtimeBucketSQL = `CASE
+			WHEN runtime < 90 THEN '0-89'
+			WHEN runtime < 120 THEN '90-119'
+			WHEN runtime < 150 THEN '120-149'
+			ELSE '150+'
+			END`
+
+// FacetCount holds the number of movies with a particular facet value. The value is the one to
+// use when filtering on the facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the movies matching the provided filters for each value of the requested facets.
+// The count for each facet leaves out the facet's own filter, so that it gives the number of
+// results there would be if that value was selected in place of (or in addition to) the current
+// selection. Genres are ordered by descending count, and decades and runtimes by value.
+func (m MovieModel) Facets(mf MovieFilters, facets []string) (map[string][]FacetCount, error) {
+	query := `
+		WITH base AS (
+			SELECT genres,
+				(year / 10 * 10)::TEXT AS decade,
+				` + runtimeBucketSQL + ` AS runtime_bucket,
+				` + genresCondition + ` AS genres_match,
+				` + decadeCondition + ` AS decade_match,
+				` + runtimeCondition + ` AS runtime_match
+			FROM movies
+			WHERE ` + movieFilterConditions + `
+		)
+		SELECT facet, value, total
+		FROM (
+			SELECT 'genres' AS facet, genre AS value, count(*) AS total, 0 AS bucket_order
+			FROM base, unnest(genres) AS genre
+			WHERE 'genres' = ANY($24) AND decade_match AND runtime_match
+			GROUP BY genre
+			UNION ALL
+			SELECT 'decade', decade, count(*), 0
+			FROM base
+			WHERE 'decade' = ANY($24) AND genres_match AND runtime_match
+			GROUP BY decade
+			UNION ALL
+			SELECT 'runtime', runtime_bucket, count(*), array_position($25, runtime_bucket)
+			FROM base
+			WHERE 'runtime' = ANY($24) AND genres_match AND decade_match
+			GROUP BY runtime_bucket
+			) AS counts
+		ORDER BY facet,
+			CASE WHEN facet = 'genres' THEN total END DESC,
+			bucket_order,
+			CASE WHEN facet = 'decade' THEN value::INTEGER END,
+			value
+		`
+
+	args := append(mf.args(), pq.Array(facets), pq.Array(RuntimeBuckets))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	// Include every requested facet in the result, even if no movies have a value for it.
+	counts := make(map[string][]FacetCount, len(facets))
+	for _, facet := range facets {
+		counts[facet] = []FacetCount{}
+	}
+
+	for rows.Next() {
+		var (
+			facet string
+			count FacetCount
+		)
+
+		err := rows.Scan(&facet, &count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[facet] = append(counts[facet], count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -8,10 +8,16 @@ import (
 )
 
 type Filters struct {
-	Page         int
-	PageSize     int
+	Page     int
+	PageSize int
+	// Sort holds one or more comma-separated sort keys, such as "-year,title", each of which
+	// must be in the SortSafeList.
 	Sort         string
 	SortSafeList []string
+	// Fields holds the fields to include in the response, or nil for all of them. Each field
+	// must be in the FieldSafeList.
+	Fields        []string
+	FieldSafeList []string
 }
 
 // Metadata holds pagination metadata.
@@ -21,6 +27,12 @@ type Metadata struct {
 	FirstPage    int `json:"first_page,omitempty"`
 	LastPage     int `json:"last_page,omitempty"`
 	TotalRecords int `json:"total_records,omitempty"`
+	// FuzzyMatch is set when a search found nothing, so the results are fuzzy matches instead,
+	// and Suggestions then holds "did you mean" suggestions for the search.
+	FuzzyMatch  bool     `json:"fuzzy_match,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	// NextCursor is set in cursor pagination mode when there is another page of results.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // calculateMetadata calculates the appropriate pagination metadata values given the total number
@@ -49,35 +61,79 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than 0")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 
-	// Check that the sort parameter matches a value in the safelist.
-	v.Check(validator.In(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+	// Check that each sort key matches a value in the safelist, and that no column is sorted on
+	// more than once.
+	var columns []string
+	for _, key := range f.sortKeys() {
+		v.Check(validator.In(key, f.SortSafeList...), "sort", "invalid sort value")
+		columns = append(columns, strings.TrimPrefix(key, "-"))
+	}
+	v.Check(validator.Unique(columns), "sort", "must not sort on the same column more than once")
+
+	// Check that each of the requested fields matches a value in the field safelist.
+	for _, field := range f.Fields {
+		v.Check(validator.In(field, f.FieldSafeList...), "fields", "invalid field")
+	}
+	v.Check(validator.Unique(f.Fields), "fields", "must not contain duplicate values")
 }
 
-// sortColumn checks that the client-provided Sort field matches one of the entries in our
-// SortSafeList and if it does, it extracts the column name from the Sort field by stripping the
-// leading hyphen character (if one exists).
-func (f Filters) sortColumn() string {
+// sortKeys splits the Sort field into its comma-separated sort keys.
+func (f Filters) sortKeys() []string {
+	keys := strings.Split(f.Sort, ",")
+	for i := range keys {
+		keys[i] = strings.TrimSpace(keys[i])
+	}
+	return keys
+}
+
+// keyColumn checks that the sort key matches one of the entries in our SortSafeList and if it
+// does, it extracts the column name from the key by stripping the leading hyphen character
+// (if one exists).
+func (f Filters) keyColumn(key string) string {
 	for _, safeValue := range f.SortSafeList {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
+		if key == safeValue {
+			return strings.TrimPrefix(key, "-")
 		}
 	}
 
 	// The panic below should technically not happen because the Sort value should have already
 	// been checked when calling the ValidateFilters helper function. However, this is a sensible
 	// failsafe to help stop a SQL injection attach from occurring.
-	panic("unsafe sort parameter:" + f.Sort)
+	panic("unsafe sort parameter:" + key)
 }
 
-// sortDirection returns the sort direction ("ASC" or "DESC") depending on the prefix character
-// of the Sort field.
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+// keyDirection returns the sort direction ("ASC" or "DESC") depending on the prefix character
+// of the sort key.
+func keyDirection(key string) string {
+	if strings.HasPrefix(key, "-") {
 		return "DESC"
 	}
 	return "ASC"
 }
 
+// sortColumn returns the column name of the first sort key.
+func (f Filters) sortColumn() string {
+	return f.keyColumn(f.sortKeys()[0])
+}
+
+// sortDirection returns the sort direction ("ASC" or "DESC") of the first sort key.
+func (f Filters) sortDirection() string {
+	return keyDirection(f.sortKeys()[0])
+}
+
+// orderBy returns the terms of an ORDER BY clause for the sort keys, such as "year DESC, title
+// ASC", with each column name prefixed by prefix (e.g. "ratings.").
+func (f Filters) orderBy(prefix string) string {
+	keys := f.sortKeys()
+
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		terms[i] = prefix + f.keyColumn(key) + " " + keyDirection(key)
+	}
+
+	return strings.Join(terms, ", ")
+}
+
 func (f Filters) limit() int {
 	return f.PageSize
 }
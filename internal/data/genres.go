@@ -0,0 +1,163 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Genre type whose fields describe a canonical genre, the aliases which map onto it, and the
+// number of movies in the genre.
+type Genre struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases"`
+	MovieCount int      `json:"movie_count"`
+}
+
+// GenreIndex maps the normalised form of every canonical genre name and alias to the canonical
+// genre name. It is used by ValidateMovie to normalise the genres of a movie.
+type GenreIndex map[string]string
+
+// GenreModel struct wraps a sql.DB connection pool and allows us to work with the Genre struct
+// type and the genres and genre_aliases tables in our database.
+type GenreModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// genreKey normalises a genre name for lookups in a GenreIndex, so that differences in case and
+// whitespace are ignored.
+func genreKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Canonical returns the canonical genre name for a genre name or alias, and whether it was found.
+func (gi GenreIndex) Canonical(name string) (string, bool) {
+	canonical, ok := gi[genreKey(name)]
+	return canonical, ok
+}
+
+// Normalise maps each of the provided genres to its canonical name, dropping any duplicates that
+// this creates (e.g. "sci-fi" and "Science Fiction"). Any genres which aren't in the index are
+// returned in the unknown slice, and left out of the normalised slice.
+func (gi GenreIndex) Normalise(genres []string) (normalised []string, unknown []string) {
+	seen := make(map[string]bool)
+
+	for _, genre := range genres {
+		canonical, ok := gi.Canonical(genre)
+		if !ok {
+			unknown = append(unknown, genre)
+			continue
+		}
+
+		if !seen[canonical] {
+			seen[canonical] = true
+			normalised = append(normalised, canonical)
+		}
+	}
+
+	return normalised, unknown
+}
+
+// Index returns a GenreIndex containing every canonical genre and alias.
+func (m GenreModel) Index() (GenreIndex, error) {
+	query := `
+		SELECT genres.name, genres.name
+		FROM genres
+		UNION ALL
+		SELECT genre_aliases.alias, genres.name
+		FROM genre_aliases
+			INNER JOIN genres ON genres.id = genre_aliases.genre_id
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	index := make(GenreIndex)
+
+	for rows.Next() {
+		var name, canonical string
+
+		err := rows.Scan(&name, &canonical)
+		if err != nil {
+			return nil, err
+		}
+
+		index[genreKey(name)] = canonical
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// GetAll returns every canonical genre along with its aliases and the number of movies in it,
+// ordered by name.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT genres.id, genres.name,
+			ARRAY(
+				SELECT genre_aliases.alias::TEXT FROM genre_aliases
+				WHERE genre_aliases.genre_id = genres.id
+				ORDER BY genre_aliases.alias
+				),
+			(SELECT count(*) FROM movies WHERE movies.genres @> ARRAY[genres.name::TEXT])
+		FROM genres
+		ORDER BY genres.name
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(&genre.ID, &genre.Name, pq.Array(&genre.Aliases), &genre.MovieCount)
+		if err != nil {
+			return nil, err
+		}
+
+		// Make sure genres without any aliases are encoded as an empty JSON array, not null.
+		if genre.Aliases == nil {
+			genre.Aliases = []string{}
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
@@ -0,0 +1,231 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ImageKinds holds the kinds of artwork that can be attached to a movie.
+var ImageKinds = []string{"poster", "backdrop", "still"}
+
+// MovieImage type whose fields describe a piece of artwork attached to a movie. Width, Height
+// and ContentType describe the original upload, and Variants holds each of the stored sizes.
+type MovieImage struct {
+	ID          int64         `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	MovieID     int64         `json:"movie_id"`
+	Kind        string        `json:"kind"`
+	ContentType string        `json:"content_type"`
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	Variants    ImageVariants `json:"variants"`
+}
+
+// ImageVariant describes one stored size of an image. The storage key is never exposed to
+// clients; the URL is filled in from the key by the storage backend when the image is returned.
+type ImageVariant struct {
+	Key    string `json:"-"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageVariants maps variant names (e.g. "small" or "original") to the stored variant. It is
+// stored as a JSONB column.
+type ImageVariants map[string]*ImageVariant
+
+// storedImageVariant is the form in which an ImageVariant is stored in the database.
+type storedImageVariant struct {
+	Key    string `json:"key"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Value implements the driver.Valuer interface, so that ImageVariants can be written to the
+// database as JSON.
+func (iv ImageVariants) Value() (driver.Value, error) {
+	stored := make(map[string]storedImageVariant, len(iv))
+	for name, variant := range iv {
+		stored[name] = storedImageVariant{Key: variant.Key, Width: variant.Width, Height: variant.Height}
+	}
+	return json.Marshal(stored)
+}
+
+// Scan implements the sql.Scanner interface, so that ImageVariants can be read from a JSONB
+// column.
+func (iv *ImageVariants) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into ImageVariants", src)
+	}
+
+	var stored map[string]storedImageVariant
+
+	err := json.Unmarshal(b, &stored)
+	if err != nil {
+		return err
+	}
+
+	*iv = make(ImageVariants, len(stored))
+	for name, variant := range stored {
+		(*iv)[name] = &ImageVariant{Key: variant.Key, Width: variant.Width, Height: variant.Height}
+	}
+
+	return nil
+}
+
+// Keys returns the storage keys of all of the variants.
+func (iv ImageVariants) Keys() []string {
+	keys := make([]string, 0, len(iv))
+	for _, variant := range iv {
+		keys = append(keys, variant.Key)
+	}
+	return keys
+}
+
+// MovieImageModel struct wraps a sql.DB connection pool and allows us to work with the
+// MovieImage struct type and the movie_images table in our database. The image files themselves
+// are kept in a storage backend, not the database.
+type MovieImageModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// Insert inserts a new record in the movie_images table. It returns ErrRecordNotFound if the
+// movie doesn't exist.
+func (m MovieImageModel) Insert(image *MovieImage) error {
+	query := `
+		INSERT INTO movie_images (movie_id, kind, content_type, width, height, variants)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+		`
+
+	args := []interface{}{image.MovieID, image.Kind, image.ContentType, image.Width, image.Height, image.Variants}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "movie_images" violates foreign key constraint "movie_images_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes a specific image for a movie, and returns the deleted record so that the
+// caller can remove its files from storage.
+func (m MovieImageModel) Delete(movieID, id int64) (*MovieImage, error) {
+	if movieID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_images
+		WHERE movie_id = $1 AND id = $2
+		RETURNING id, created_at, movie_id, kind, content_type, width, height, variants
+		`
+
+	var image MovieImage
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, id).Scan(
+		&image.ID,
+		&image.CreatedAt,
+		&image.MovieID,
+		&image.Kind,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.Variants,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
+
+// GetAllForMovies returns the images for each of the provided movie IDs in a single query,
+// keyed by movie ID, in the order they were uploaded.
+func (m MovieImageModel) GetAllForMovies(movieIDs ...int64) (map[int64][]*MovieImage, error) {
+	images := make(map[int64][]*MovieImage)
+
+	if len(movieIDs) == 0 {
+		return images, nil
+	}
+
+	query := `
+		SELECT id, created_at, movie_id, kind, content_type, width, height, variants
+		FROM movie_images
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id, id
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var image MovieImage
+
+		err := rows.Scan(
+			&image.ID,
+			&image.CreatedAt,
+			&image.MovieID,
+			&image.Kind,
+			&image.ContentType,
+			&image.Width,
+			&image.Height,
+			&image.Variants,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		images[image.MovieID] = append(images[image.MovieID], &image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// ValidateMovieImage runs validation checks on the MovieImage type.
+func ValidateMovieImage(v *validator.Validator, image *MovieImage) {
+	v.Check(image.Kind != "", "kind", "must be provided")
+	v.Check(validator.In(image.Kind, ImageKinds...), "kind", "invalid image kind")
+}
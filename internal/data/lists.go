@@ -0,0 +1,623 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Define the visibility levels for a list. Private lists are only visible to their owner,
+// unlisted lists are visible to anyone with the share token, and public lists are visible to
+// everyone.
+const (
+	ListPrivate  = "private"
+	ListUnlisted = "unlisted"
+	ListPublic   = "public"
+)
+
+var (
+	// ErrDuplicateListEntry is returned when a movie is added to a list it's already on.
+	ErrDuplicateListEntry = errors.New("duplicate list entry")
+)
+
+// List type whose fields describe a user-owned list of movies. Note that the ShareToken is only
+// included in JSON output when it has been set, so handlers should clear it for anyone other
+// than the list owner.
+type List struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"`
+	ShareToken  string    `json:"share_token,omitempty"`
+	EntryCount  int       `json:"entry_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	Version     int32     `json:"version"`
+}
+
+// ListEntry type whose fields describe a single movie on a list. Position is 1-based.
+type ListEntry struct {
+	ListID   int64     `json:"-"`
+	MovieID  int64     `json:"movie_id"`
+	Position int32     `json:"position"`
+	Notes    string    `json:"notes,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie,omitempty"`
+}
+
+// ListModel struct wraps a sql.DB connection pool and allows us to work with the List and
+// ListEntry struct types and the lists and list_entries tables in our database.
+type ListModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// VisibleTo checks whether a list can be viewed by a user, given the share token (if any) that
+// was supplied with the request.
+func (l *List) VisibleTo(user *User, shareToken string) bool {
+	switch {
+	case !user.IsAnonymous() && l.UserID == user.ID:
+		return true
+	case l.Visibility == ListPublic:
+		return true
+	case l.Visibility == ListUnlisted:
+		return shareToken != "" && shareToken == l.ShareToken
+	default:
+		return false
+	}
+}
+
+// OwnedBy checks whether a list belongs to a user.
+func (l *List) OwnedBy(user *User) bool {
+	return !user.IsAnonymous() && l.UserID == user.ID
+}
+
+// SetShareToken generates a new random share token for the list. Any previously shared links
+// to an unlisted list stop working once the list is saved with the new token.
+func (l *List) SetShareToken() error {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return err
+	}
+
+	l.ShareToken = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	return nil
+}
+
+// Insert inserts a new record in the lists table.
+func (m ListModel) Insert(list *List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description, visibility, share_token)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, version
+		`
+
+	args := []interface{}{list.UserID, list.Name, list.Description, list.Visibility, list.ShareToken}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+// Get fetches a record from the lists table, along with the number of entries on the list.
+func (m ListModel) Get(id int64) (*List, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT lists.id, lists.user_id, lists.name, lists.description, lists.visibility,
+			lists.share_token, (SELECT count(*) FROM list_entries WHERE list_id = lists.id),
+			lists.created_at, lists.version
+		FROM lists
+		WHERE lists.id = $1
+		`
+
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&list.ID,
+		&list.UserID,
+		&list.Name,
+		&list.Description,
+		&list.Visibility,
+		&list.ShareToken,
+		&list.EntryCount,
+		&list.CreatedAt,
+		&list.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+// Update updates a specific list in the lists table, using the version number for optimistic
+// concurrency control.
+func (m ListModel) Update(list *List) error {
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, visibility = $3, share_token = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version
+		`
+
+	args := []interface{}{
+		list.Name,
+		list.Description,
+		list.Visibility,
+		list.ShareToken,
+		list.ID,
+		list.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes a specific record in the lists table. The list entries are removed by the
+// ON DELETE CASCADE constraint on the list_entries table.
+func (m ListModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM lists
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll returns a paginated list of lists. If userID is non-zero then only the lists owned by
+// that user are returned, and if visibility is non-empty then only lists with that visibility
+// are returned.
+func (m ListModel) GetAll(userID int64, visibility string, filters Filters) ([]*List, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), lists.id, lists.user_id, lists.name, lists.description,
+			lists.visibility, lists.share_token,
+			(SELECT count(*) FROM list_entries WHERE list_id = lists.id),
+			lists.created_at, lists.version
+		FROM lists
+		WHERE (lists.user_id = $1 OR $1 = 0)
+		AND (lists.visibility = $2 OR $2 = '')
+		ORDER BY %s, lists.id ASC
+		LIMIT $3 OFFSET $4`,
+		filters.orderBy("lists."))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{userID, visibility, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	lists := []*List{}
+
+	for rows.Next() {
+		var list List
+
+		err := rows.Scan(
+			&totalRecords,
+			&list.ID,
+			&list.UserID,
+			&list.Name,
+			&list.Description,
+			&list.Visibility,
+			&list.ShareToken,
+			&list.EntryCount,
+			&list.CreatedAt,
+			&list.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return lists, metadata, nil
+}
+
+// GetEntries returns a paginated list of the entries on a list, along with the movie for each
+// entry. Entry positions are numbered from 1 at read time, so any gaps left behind by deleted
+// movies are never visible to clients.
+func (m ListModel) GetEntries(listID int64, filters Filters) ([]*ListEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(),
+			ROW_NUMBER() OVER (ORDER BY list_entries.position, list_entries.added_at, list_entries.movie_id),
+			list_entries.list_id, list_entries.movie_id, list_entries.notes, list_entries.added_at,
+			movies.title, movies.year, movies.runtime, movies.genres, movies.average_rating,
+			movies.rating_count, movies.version
+		FROM list_entries
+			INNER JOIN movies ON movies.id = list_entries.movie_id
+		WHERE list_entries.list_id = $1
+		ORDER BY %s, list_entries.movie_id ASC
+		LIMIT $2 OFFSET $3`,
+		filters.orderBy("list_entries."))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	entries := []*ListEntry{}
+
+	for rows.Next() {
+		var entry ListEntry
+		var movie Movie
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.Position,
+			&entry.ListID,
+			&entry.MovieID,
+			&entry.Notes,
+			&entry.AddedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movie.ID = entry.MovieID
+		entry.Movie = &movie
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
+
+// renumberEntries rewrites the stored positions of a list's entries so that they run from 1
+// without any gaps, as part of the provided transaction, and returns the number of entries. It
+// locks the list record first so that concurrent changes to the same list are serialized.
+func renumberEntries(ctx context.Context, tx *sql.Tx, listID int64) (int32, error) {
+	var id int64
+
+	err := tx.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	query := `
+		UPDATE list_entries
+		SET position = ordered.rn
+		FROM (
+			SELECT movie_id, ROW_NUMBER() OVER (ORDER BY position, added_at, movie_id) AS rn
+			FROM list_entries
+			WHERE list_id = $1
+			) AS ordered
+		WHERE list_entries.list_id = $1 AND list_entries.movie_id = ordered.movie_id
+		`
+
+	result, err := tx.ExecContext(ctx, query, listID)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(count), nil
+}
+
+// AddEntry adds a movie to a list at the requested position, shifting any later entries down.
+// A zero position (or one past the end of the list) appends the movie to the end of the list.
+func (m ListModel) AddEntry(entry *ListEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	count, err := renumberEntries(ctx, tx, entry.ListID)
+	if err != nil {
+		return err
+	}
+
+	if entry.Position < 1 || entry.Position > count+1 {
+		entry.Position = count + 1
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE list_entries
+		SET position = position + 1
+		WHERE list_id = $1 AND position >= $2
+		`, entry.ListID, entry.Position)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO list_entries (list_id, movie_id, position, notes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING added_at
+		`
+
+	args := []interface{}{entry.ListID, entry.MovieID, entry.Position, entry.Notes}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&entry.AddedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "list_entries_pkey"`:
+			return ErrDuplicateListEntry
+		case err.Error() == `pq: insert or update on table "list_entries" violates foreign key constraint "list_entries_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateEntry moves an existing list entry to a new position and updates its notes. A zero
+// position leaves the entry where it is, and a position past the end of the list moves it to
+// the end.
+func (m ListModel) UpdateEntry(entry *ListEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	count, err := renumberEntries(ctx, tx, entry.ListID)
+	if err != nil {
+		return err
+	}
+
+	var current int32
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT position
+		FROM list_entries
+		WHERE list_id = $1 AND movie_id = $2
+		`, entry.ListID, entry.MovieID).Scan(&current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if entry.Position < 1 {
+		entry.Position = current
+	}
+	if entry.Position > count {
+		entry.Position = count
+	}
+
+	// Shift the entries between the old and new positions up or down by one place to make room
+	// for the entry being moved.
+	switch {
+	case entry.Position < current:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE list_entries
+			SET position = position + 1
+			WHERE list_id = $1 AND position >= $2 AND position < $3
+			`, entry.ListID, entry.Position, current)
+	case entry.Position > current:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE list_entries
+			SET position = position - 1
+			WHERE list_id = $1 AND position > $2 AND position <= $3
+			`, entry.ListID, current, entry.Position)
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE list_entries
+		SET position = $1, notes = $2
+		WHERE list_id = $3 AND movie_id = $4
+		RETURNING added_at
+		`
+
+	args := []interface{}{entry.Position, entry.Notes, entry.ListID, entry.MovieID}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&entry.AddedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetEntry returns a single entry from a list.
+func (m ListModel) GetEntry(listID, movieID int64) (*ListEntry, error) {
+	if listID < 1 || movieID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT list_id, movie_id, position, notes, added_at
+		FROM list_entries
+		WHERE list_id = $1 AND movie_id = $2
+		`
+
+	var entry ListEntry
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, listID, movieID).Scan(
+		&entry.ListID,
+		&entry.MovieID,
+		&entry.Position,
+		&entry.Notes,
+		&entry.AddedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+// RemoveEntry removes a movie from a list, closing the gap in the positions of the remaining
+// entries.
+func (m ListModel) RemoveEntry(listID, movieID int64) error {
+	if listID < 1 || movieID < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM list_entries
+		WHERE list_id = $1 AND movie_id = $2
+		`, listID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = renumberEntries(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ValidateList runs validation checks on the List type.
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(len(list.Description) <= 10_000, "description", "must not be more than 10000 bytes long")
+
+	v.Check(validator.In(list.Visibility, ListPrivate, ListUnlisted, ListPublic), "visibility",
+		"must be one of private, unlisted or public")
+}
+
+// ValidateListEntry runs validation checks on the ListEntry type.
+func ValidateListEntry(v *validator.Validator, entry *ListEntry) {
+	v.Check(entry.MovieID > 0, "movie_id", "must be a positive integer")
+	v.Check(entry.Position >= 0, "position", "must not be negative")
+	v.Check(len(entry.Notes) <= 5_000, "notes", "must not be more than 5000 bytes long")
+}
@@ -17,10 +17,19 @@ var (
 
 // Models struct is a single convenient container to hold and represent all our database models.
 type Models struct {
-	Movies      MovieModel
-	Users       UserModel
-	Tokens      TokenModel
-	Permissions PermissionModel
+	Movies         MovieModel
+	MovieRevisions MovieRevisionModel
+	Ratings        RatingModel
+	Lists          ListModel
+	People         PersonModel
+	Credits        CreditModel
+	MovieTitles    MovieTitleModel
+	Collections    CollectionModel
+	MovieImages    MovieImageModel
+	Genres         GenreModel
+	Users          UserModel
+	Tokens         TokenModel
+	Permissions    PermissionModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -32,6 +41,51 @@ func NewModels(db *sql.DB) Models {
 			InfoLog:  infoLog,
 			ErrorLog: errorLog,
 		},
+		MovieRevisions: MovieRevisionModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Ratings: RatingModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Lists: ListModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		People: PersonModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Credits: CreditModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		MovieTitles: MovieTitleModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Collections: CollectionModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		MovieImages: MovieImageModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Genres: GenreModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
 		Users: UserModel{
 			DB:       db,
 			InfoLog:  infoLog,
@@ -49,3 +103,12 @@ func NewModels(db *sql.DB) Models {
 		},
 	}
 }
+
+// rollbackTx rolls back the provided transaction, logging any error other than sql.ErrTxDone. This
+// is intended to be deferred straight after a transaction is started, and it is a no-op if the
+// transaction has already been committed.
+func rollbackTx(tx *sql.Tx, errorLog *log.Logger) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		errorLog.Println(err)
+	}
+}
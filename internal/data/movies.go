@@ -3,9 +3,13 @@ package data
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
@@ -24,8 +28,335 @@ type Movie struct {
 	Year      int32     `json:"year,omitempty"` // Movie release year0
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"` // The version number starts at 1 and is incremented each
+	// Descriptive metadata. Languages are ISO 639 codes, and Certifications maps ISO 3166-1
+	// country codes to the age certification the movie was given in that country.
+	Synopsis      string `json:"synopsis,omitempty"`
+	OriginalTitle string `json:"original_title,omitempty"`
+	// TitleLocale is set when Title has been replaced by one of the movie's alternate titles,
+	// and holds the language tag of that title.
+	TitleLocale    string         `json:"title_locale,omitempty"`
+	Languages      []string       `json:"languages,omitempty"`
+	Certifications Certifications `json:"certifications,omitempty"`
+	// The release status is one of ReleaseStatuses, and ReleaseDates maps ISO 3166-1 country
+	// codes to the date the movie is (or was) released in that country.
+	ReleaseStatus string       `json:"release_status,omitempty"`
+	ReleaseDates  ReleaseDates `json:"release_dates,omitempty"`
+	// External identifiers for the movie on other services.
+	IMDbID string `json:"imdb_id,omitempty"`
+	TMDBID int64  `json:"tmdb_id,omitempty"`
+	// The average rating and rating count are maintained by the database from the ratings
+	// table, so they are never written by the MovieModel methods.
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int32   `json:"rating_count"`
+	// Credits, collections and images are loaded separately by the CreditModel,
+	// CollectionModel and MovieImageModel, rather than by the MovieModel methods.
+	Credits     []*Credit          `json:"credits,omitempty"`
+	Collections []*MovieCollection `json:"collections,omitempty"`
+	// Relevance and Highlights are only set when listing movies with a search query.
+	Relevance  float64           `json:"relevance,omitempty"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+	// Score is only set when listing similar or recommended movies.
+	Score   float64       `json:"score,omitempty"`
+	Images  []*MovieImage `json:"images,omitempty"`
+	Version int32         `json:"version"` // The version number starts at 1 and is incremented each
 	// time the movie information is updated.
+
+	runtimeFormat RuntimeFormat // The format of the runtime in the JSON output, if not the default.
+}
+
+// SetRuntimeFormat sets the format of the movie's runtime in its JSON output.
+func (movie *Movie) SetRuntimeFormat(format RuntimeFormat) {
+	movie.runtimeFormat = format
+}
+
+// MarshalJSON encodes the movie as JSON, with its runtime in the format set by
+// SetRuntimeFormat.
+func (movie Movie) MarshalJSON() ([]byte, error) {
+	// The movieJSON type has the same fields as Movie but none of its methods, so encoding it
+	// doesn't call this method again.
+	type movieJSON Movie
+
+	if movie.runtimeFormat == "" || movie.runtimeFormat == RuntimeMins {
+		return json.Marshal(movieJSON(movie))
+	}
+
+	// The Runtime field of the outer struct hides the one of the embedded movieJSON.
+	var runtime interface{}
+	if movie.Runtime != 0 {
+		runtime = movie.Runtime.Format(movie.runtimeFormat)
+	}
+
+	return json.Marshal(struct {
+		movieJSON
+		Runtime interface{} `json:"runtime,omitempty"`
+	}{movieJSON(movie), runtime})
+}
+
+// MovieFilters holds the movie-specific filters that can be applied when listing movies with
+// MovieModel.GetAll. Zero values and empty slices mean that the filter isn't applied, but note
+// that a nil slice is sent to Postgres as NULL, so use NewMovieFilters to create them.
+type MovieFilters struct {
+	Search string // A tsquery, as returned by ParseSearchQuery.
+	// Only include movies whose title is similar to this text, using trigram matching. This is
+	// used as a fallback when Search doesn't match anything.
+	Fuzzy    string
+	Genres   []string
+	PersonID int64  // Only include movies which credit this person.
+	Role     string // Only include movies where PersonID is credited with this role.
+	Language string // Only include movies with this spoken language.
+	// Only include movies with a certification in this country. If Certification is also set
+	// then the certification must match it.
+	CertificationCountry string
+	Certification        string
+	IMDbID               string
+	TMDBID               int64
+	Decades              []int64  // Only include movies released in one of these decades, e.g. 1990.
+	RuntimeBuckets       []string // Only include movies in one of these RuntimeBuckets.
+	// Inclusive ranges for the year and runtime, and an exclusive range for when the movie was
+	// added.
+	YearMin       int32
+	YearMax       int32
+	RuntimeMin    int32
+	RuntimeMax    int32
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	GenresAny     []string // Only include movies with at least one of these genres.
+	GenresNone    []string // Only include movies with none of these genres.
+	IDs           []int64  // Only include the movies with these IDs.
+	CollectionID  int64    // Only include movies in this collection.
+	ReleaseStatus string   // Only include movies with this release status.
+}
+
+// NewMovieFilters returns a MovieFilters which doesn't filter anything, with every slice filter
+// set to an empty slice.
+func NewMovieFilters() MovieFilters {
+	return MovieFilters{
+		Genres:         []string{},
+		Decades:        []int64{},
+		RuntimeBuckets: []string{},
+		GenresAny:      []string{},
+		GenresNone:     []string{},
+		IDs:            []int64{},
+	}
+}
+
+// args returns the placeholder parameter values for movieFilterConditions and the facet
+// conditions, in order.
+func (mf MovieFilters) args() []interface{} {
+	return []interface{}{
+		mf.Search,
+		pq.Array(mf.Genres),
+		mf.PersonID,
+		mf.Role,
+		mf.Language,
+		mf.CertificationCountry,
+		mf.Certification,
+		mf.IMDbID,
+		mf.TMDBID,
+		mf.Fuzzy,
+		pq.Array(mf.Decades),
+		pq.Array(mf.RuntimeBuckets),
+		mf.YearMin,
+		mf.YearMax,
+		mf.RuntimeMin,
+		mf.RuntimeMax,
+		sql.NullTime{Time: mf.CreatedAfter, Valid: !mf.CreatedAfter.IsZero()},
+		sql.NullTime{Time: mf.CreatedBefore, Valid: !mf.CreatedBefore.IsZero()},
+		pq.Array(mf.GenresAny),
+		pq.Array(mf.GenresNone),
+		pq.Array(mf.IDs),
+		mf.CollectionID,
+		mf.ReleaseStatus,
+	}
+}
+
+// movieFilterConditions holds the SQL conditions for the MovieFilters which aren't facets, using
+// the placeholder parameters from MovieFilters.args. The conditions for the facet filters are
+// kept separate, so that MovieModel.Facets can leave them out. Note that the year and runtime
+// ranges aren't part of the decade and runtime facets, so they still apply to those counts.
+var movieFilterConditions = searchCondition(1) + `
+		AND (EXISTS (
+			SELECT 1 FROM credits
+			WHERE credits.movie_id = movies.id AND credits.person_id = $3 AND (credits.role = $4 OR $4 = '')
+			) OR $3 = 0)
+		AND (languages @> ARRAY[$5::TEXT] OR $5 = '')
+		AND ($6 = '' OR ($7 = '' AND certifications ? $6) OR certifications ->> $6 = $7)
+		AND (imdb_id = $8 OR $8 = '')
+		AND (tmdb_id = $9 OR $9 = 0)
+		AND ` + fuzzyCondition(10) + `
+		AND (year >= $13 OR $13 = 0)
+		AND (year <= $14 OR $14 = 0)
+		AND (runtime >= $15 OR $15 = 0)
+		AND (runtime <= $16 OR $16 = 0)
+		AND (created_at > $17 OR $17::TIMESTAMPTZ IS NULL)
+		AND (created_at < $18 OR $18::TIMESTAMPTZ IS NULL)
+		AND (COALESCE(cardinality($21::BIGINT[]), 0) = 0 OR id = ANY($21))
+		AND (EXISTS (
+			SELECT 1 FROM collection_movies
+			WHERE collection_movies.movie_id = movies.id AND collection_movies.collection_id = $22
+			) OR $22 = 0)
+		AND (release_status = $23 OR $23 = '')`
+
+// The conditions for each of the facet filters.
+const (
+	genresCondition = `((genres @> $2 OR $2 = '{}')
+		AND (genres && $19 OR $19 = '{}')
+		AND NOT (genres && $20))`
+	decadeCondition  = `(COALESCE(cardinality($11::INTEGER[]), 0) = 0 OR year / 10 * 10 = ANY($11))`
+	runtimeCondition = `(cardinality($12::TEXT[]) = 0 OR ` + runtimeBucketSQL + ` = ANY($12))`
+)
+
+var (
+	// ErrDuplicateIMDbID is returned when another movie already has the same IMDb ID.
+	ErrDuplicateIMDbID = errors.New("duplicate imdb id")
+	// ErrDuplicateTMDBID is returned when another movie already has the same TMDB ID.
+	ErrDuplicateTMDBID = errors.New("duplicate tmdb id")
+)
+
+var (
+	// LanguageRX matches ISO 639-1 and ISO 639-2/3 language codes, e.g. "en" or "fil".
+	LanguageRX = regexp.MustCompile("^[a-z]{2,3}$")
+	// CountryRX matches ISO 3166-1 alpha-2 country codes, e.g. "US".
+	CountryRX = regexp.MustCompile("^[A-Z]{2}$")
+	// IMDbIDRX matches IMDb title IDs, e.g. "tt0111161".
+	IMDbIDRX = regexp.MustCompile("^tt[0-9]{7,10}$")
+)
+
+// Certifications maps ISO 3166-1 alpha-2 country codes to the age certification a movie was
+// given in that country, e.g. {"US": "PG-13", "GB": "12A"}. It is stored as a JSONB column.
+type Certifications map[string]string
+
+// Value implements the driver.Valuer interface, so that Certifications can be written to the
+// database as JSON.
+func (c Certifications) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, so that Certifications can be read from a JSONB
+// column.
+func (c *Certifications) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	case nil:
+		*c = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Certifications", src)
+	}
+}
+
+// movieColumns lists the columns that are read for every movie, in the order expected by
+// Movie.scanDest. External IDs are nullable in the database, so they are coalesced to zero
+// values here.
+const movieColumns = `id, created_at, title, year, runtime, genres, synopsis, original_title,
+	languages, certifications, release_status, release_dates, COALESCE(imdb_id, ''),
+	COALESCE(tmdb_id, 0), average_rating, rating_count, version`
+
+// scanDest returns the scan destinations for the columns in movieColumns.
+func (movie *Movie) scanDest() []interface{} {
+	return []interface{}{
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Synopsis,
+		&movie.OriginalTitle,
+		pq.Array(&movie.Languages),
+		&movie.Certifications,
+		&movie.ReleaseStatus,
+		&movie.ReleaseDates,
+		&movie.IMDbID,
+		&movie.TMDBID,
+		&movie.AverageRating,
+		&movie.RatingCount,
+		&movie.Version,
+	}
+}
+
+// movieFields names each of the columns in movieColumns, in the same order, so that a subset of
+// them can be selected for a sparse fieldset.
+var movieFields = []struct {
+	name   string
+	column string
+}{
+	{"id", "id"},
+	{"created_at", "created_at"},
+	{"title", "title"},
+	{"year", "year"},
+	{"runtime", "runtime"},
+	{"genres", "genres"},
+	{"synopsis", "synopsis"},
+	{"original_title", "original_title"},
+	{"languages", "languages"},
+	{"certifications", "certifications"},
+	{"release_status", "release_status"},
+	{"release_dates", "release_dates"},
+	{"imdb_id", "COALESCE(imdb_id, '')"},
+	{"tmdb_id", "COALESCE(tmdb_id, 0)"},
+	{"average_rating", "average_rating"},
+	{"rating_count", "rating_count"},
+	{"version", "version"},
+}
+
+// movieSelection returns the columns to select for the fields in filters.Fields, along with a
+// function returning the scan destinations for them. Every column is selected if no fields were
+// requested. The ID and the sort columns are always selected, as they're needed for pagination.
+// Fields which aren't columns (such as credits) are left out.
+func movieSelection(filters Filters) (string, func(movie *Movie) []interface{}) {
+	if len(filters.Fields) == 0 {
+		return movieColumns, (*Movie).scanDest
+	}
+
+	wanted := map[string]bool{"id": true}
+	for _, field := range filters.Fields {
+		wanted[field] = true
+	}
+	for _, key := range filters.sortKeys() {
+		wanted[filters.keyColumn(key)] = true
+	}
+
+	var (
+		columns []string
+		indexes []int
+	)
+
+	for i, field := range movieFields {
+		if wanted[field.name] {
+			columns = append(columns, field.column)
+			indexes = append(indexes, i)
+		}
+	}
+
+	return strings.Join(columns, ", "), func(movie *Movie) []interface{} {
+		all := movie.scanDest()
+
+		dest := make([]interface{}, len(indexes))
+		for i, index := range indexes {
+			dest[i] = all[index]
+		}
+
+		return dest
+	}
+}
+
+// movieError converts constraint violations on the movies table into our own errors.
+func movieError(err error) error {
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "movies_imdb_id_key"`:
+		return ErrDuplicateIMDbID
+	case err.Error() == `pq: duplicate key value violates unique constraint "movies_tmdb_id_key"`:
+		return ErrDuplicateTMDBID
+	default:
+		return err
+	}
 }
 
 // MovieModel struct wraps a sql.DB connection pool and allows us to work with Movie struct type
@@ -37,24 +368,61 @@ type MovieModel struct {
 }
 
 // Insert accepts a pointer to a movie struct, which should contain the data for the
-// new record and inserts the record into the movies table.
-func (m MovieModel) Insert(movie *Movie) error {
-	query := `
-		INSERT INTO movies (title, year, runtime, genres) 
-		VALUES ($1, $2, $3, $4) 
-		RETURNING id, created_at, version
-		`
-
+// new record and inserts the record into the movies table. An insert revision attributed to the
+// provided user ID is recorded in the same transaction.
+func (m MovieModel) Insert(movie *Movie, userID int64) error {
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	err = m.insertTx(ctx, tx, movie, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertTx inserts a new movie and records its insert revision as part of the provided
+// transaction.
+func (m MovieModel) insertTx(ctx context.Context, tx *sql.Tx, movie *Movie, userID int64) error {
+	query := `
+		INSERT INTO movies (title, year, runtime, genres, synopsis, original_title, languages,
+			certifications, imdb_id, tmdb_id, release_status, release_dates)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, 0), $11, $12)
+		RETURNING id, created_at, version
+		`
+
 	// Create an args slice containing the values for the placeholder parameters from the movie
 	// struct. Declaring this slice immediately next to our SQL query helps to make it nice and
 	// clear *what values are being user where* in the query
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []interface{}{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		movie.Synopsis,
+		movie.OriginalTitle,
+		pq.Array(movie.Languages),
+		movie.Certifications,
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.ReleaseStatus,
+		movie.ReleaseDates,
+	}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return movieError(err)
+	}
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	return insertRevision(ctx, tx, RevisionInsert, userID, nil, movie)
 }
 
 // Get fetches a record from the movies table and returns the corresponding Movie struct.
@@ -69,10 +437,10 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	}
 
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
-        FROM movies
- 		WHERE id = $1
- 		`
+		SELECT ` + movieColumns + `
+		FROM movies
+		WHERE id = $1
+		`
 
 	var movie Movie
 
@@ -85,14 +453,7 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 
 	// Use the QueryRowContext() method to execute the query, passing in the context with the
 	// deadline ctx as the first argument.
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(movie.scanDest()...)
 
 	// Handle any errors. If there was no matching movie found, Scan() will return a sql.ErrNoRows
 	// error. We check for this and return our custom ErrRecordNotFound error instead.
@@ -108,12 +469,63 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-// Update updates a specific movie in the movies table.
-func (m MovieModel) Update(movie *Movie) error {
+// getForUpdate fetches a movie record as part of the provided transaction, locking the row
+// until the transaction ends. This gives us a consistent "before" state to record revisions
+// against.
+func (m MovieModel) getForUpdate(ctx context.Context, tx *sql.Tx, id int64) (*Movie, error) {
+	query := `
+		SELECT ` + movieColumns + `
+		FROM movies
+		WHERE id = $1
+		FOR UPDATE
+		`
+
+	var movie Movie
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(movie.scanDest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
+// Update updates a specific movie in the movies table. An update revision attributed to the
+// provided user ID is recorded in the same transaction.
+func (m MovieModel) Update(movie *Movie, userID int64) error {
+	// Create a context with a 3-second timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	err = m.updateTx(ctx, tx, movie, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateTx updates a movie and records its update revision as part of the provided
+// transaction. It returns ErrEditConflict if the movie's version has changed or it has been
+// deleted.
+func (m MovieModel) updateTx(ctx context.Context, tx *sql.Tx, movie *Movie, userID int64) error {
 	query := `
 		UPDATE movies
-		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		SET title = $1, year = $2, runtime = $3, genres = $4, synopsis = $5, original_title = $6,
+			languages = $7, certifications = $8, imdb_id = NULLIF($9, ''), tmdb_id = NULLIF($10, 0),
+			release_status = $11, release_dates = $12, version = version + 1
+		WHERE id = $13 AND version = $14
 		RETURNING version
 		`
 
@@ -123,93 +535,133 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Year,
 		movie.Runtime,
 		pq.Array(movie.Genres),
+		movie.Synopsis,
+		movie.OriginalTitle,
+		pq.Array(movie.Languages),
+		movie.Certifications,
+		movie.IMDbID,
+		movie.TMDBID,
+		movie.ReleaseStatus,
+		movie.ReleaseDates,
 		movie.ID,
 		movie.Version, // Add the expected movie version.
 	}
 
-	// Create a context with a 3-second timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	// Lock the current movie record so that we can diff against it. If the record has been
+	// deleted in the meantime then this is also an edit conflict.
+	before, err := m.getForUpdate(ctx, tx, movie.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
 
 	// Execute the SQL query. If no matching row could be found, we know the movie version
 	// has changed (or the record has been deleted) and we return ErrEditConflict.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return movieError(err)
 		}
 	}
 
-	return nil
+	return insertRevision(ctx, tx, RevisionUpdate, userID, before, movie)
 }
 
-// Delete is a placeholder method for deleting a specific record in the movies table.
-func (m MovieModel) Delete(id int64) error {
+// Delete deletes a specific record in the movies table. A delete revision attributed to the
+// provided user ID is recorded in the same transaction. If version is non-zero, it returns
+// ErrEditConflict unless the movie is at that version.
+func (m MovieModel) Delete(id int64, version int32, userID int64) error {
 	// Return an ErrRecordNotFound error if the movie ID is less than 1
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `
-		DELETE FROM movies
-		WHERE id = $1
-		`
-
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// Execute the SQL query using the Exec() method,
-	// passing in the id variable as the value for the placeholder parameter. The Exec(
-	// ) method returns a sql.Result object.
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer rollbackTx(tx, m.ErrorLog)
 
-	// Call the RowsAffected() method on the sql.Result
-	// object to get the number of rows affected by the query.
-	rowsAffected, err := result.RowsAffected()
+	err = m.deleteTx(ctx, tx, id, version, userID)
 	if err != nil {
 		return err
 	}
 
-	// If no rows were affected,
-	// we know that the movies table didn't contain a record with the provided ID at the moment
-	// we tried to delete it. In that case we return an ErrRecordNotFound error.
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	return tx.Commit()
+}
+
+// deleteTx deletes a movie and records its delete revision as part of the provided
+// transaction. If version is non-zero, it returns ErrEditConflict unless the movie is at that
+// version.
+func (m MovieModel) deleteTx(ctx context.Context, tx *sql.Tx, id int64, version int32, userID int64) error {
+	query := `
+		DELETE FROM movies
+		WHERE id = $1
+		`
+
+	// Lock the movie record before deleting it, so that we can record its final state. If there
+	// is no matching record this returns an ErrRecordNotFound error.
+	before, err := m.getForUpdate(ctx, tx, id)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if version != 0 && before.Version != version {
+		return ErrEditConflict
+	}
+
+	// Execute the SQL query using the Exec() method,
+	// passing in the id variable as the value for the placeholder parameter. The Exec(
+	// ) method returns a sql.Result object.
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, RevisionDelete, userID, before, nil)
 }
 
 // GetAll returns a list of movies in the form of a string of Movie type based on a set of
 // provided filters.
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	// Add an ORDER BY clause and interpolate the sort column and direction using fmt.Sprintf.
-	// Importantly, notice that we also include a secondary sort on the movie ID to ensure
+func (m MovieModel) GetAll(mf MovieFilters, filters Filters) ([]*Movie, Metadata, error) {
+	// Add an ORDER BY clause and interpolate the sort columns and directions using fmt.Sprintf.
+	// Importantly, notice that we also include a final sort on the movie ID to ensure
 	// a consistent ordering. Furthermore, we include LIMIT and OFFSET clauses with placeholder
 	// parameter values for pagination implementation. The window function is used to calculate
-	// the total filtered rows which will be used in our pagination metadata.
+	// the total filtered rows which will be used in our pagination metadata. The relevance of
+	// each movie to the search query (or fuzzy match text) is also selected, so that it can be
+	// sorted on.
+	// Only the columns for the requested fields are selected.
+	columns, scanDest := movieSelection(filters)
+
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+		SELECT count(*) OVER(), `+columns+`, `+searchRank(1)+` + `+fuzzyRank(10)+` AS relevance
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`,
-		filters.sortColumn(), filters.sortDirection())
+		WHERE `+movieFilterConditions+`
+		AND `+genresCondition+`
+		AND `+decadeCondition+`
+		AND `+runtimeCondition+`
+		ORDER BY %s, id ASC
+		LIMIT $24 OFFSET $25`,
+		filters.orderBy(""))
 
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// Organize our four placeholder parameter values in a slice.
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	// Organize our placeholder parameter values in a slice.
+	args := append(mf.args(), filters.limit(), filters.offset())
 
 	// Use QueryContext to execute the query. This returns a sql.Rows result set containing
 	// the result.
@@ -237,18 +689,10 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 		// Initialize an empty Movie struct to hold the data for an individual movie.
 		var movie Movie
 
-		// Scan the values from the row into the Movie struct. Again, note that we're using
-		// the pq.Array adapter on the genres field.
-		err := rows.Scan(
-			&totalRecords, // Scan the count from the window function into totalRecords.
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
+		// Scan the values from the row into the Movie struct, after scanning the count from the
+		// window function into totalRecords.
+		dest := append([]interface{}{&totalRecords}, scanDest(&movie)...)
+		err := rows.Scan(append(dest, &movie.Relevance)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -263,6 +707,14 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 		return nil, Metadata{}, err
 	}
 
+	// Highlight the search terms in the page of movies.
+	if mf.Search != "" {
+		err = m.highlight(ctx, mf.Search, movies)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
 	// Generate a Metadata struct, passing in the total record count and pagination parameters
 	// from the client.
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
@@ -271,8 +723,68 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
-// ValidateMovie runs validation checks on the Movie type.
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// highlight sets the Highlights of each of the provided movies to snippets of their title and
+// synopsis with the words matching the search query wrapped in <mark> tags. This is done in a
+// separate query so that ts_headline, which is relatively expensive, only runs for the movies
+// being returned rather than for every match.
+func (m MovieModel) highlight(ctx context.Context, search string, movies []*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT id,
+			ts_headline(search_config, title, to_tsquery(search_config, $1),
+				'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+			ts_headline(search_config, synopsis, to_tsquery(search_config, $1),
+				'MaxFragments=2, MaxWords=35, MinWords=15, StartSel=<mark>, StopSel=</mark>')
+		FROM movies
+		WHERE id = ANY($2)
+		`
+
+	ids := make([]int64, len(movies))
+	byID := make(map[int64]*Movie, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+		byID[movie.ID] = movie
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, search, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			id              int64
+			title, synopsis string
+		)
+
+		err := rows.Scan(&id, &title, &synopsis)
+		if err != nil {
+			return err
+		}
+
+		highlights := map[string]string{"title": title}
+		if synopsis != "" {
+			highlights["synopsis"] = synopsis
+		}
+
+		byID[id].Highlights = highlights
+	}
+
+	return rows.Err()
+}
+
+// ValidateMovie runs validation checks on the Movie type. If a GenreIndex is provided then the
+// movie's genres are first normalised to their canonical names, and any unknown genres are
+// rejected.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres GenreIndex) {
 	// Check movie.Title
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
@@ -280,16 +792,103 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	// Check movie.Year
 	v.Check(movie.Year != 0, "year", "must be provided")
 	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+
+	// Movies are released unless they say otherwise. Only unreleased movies can have a year in
+	// the future, which is the year they're expected to be released.
+	if movie.ReleaseStatus == "" {
+		movie.ReleaseStatus = ReleaseReleased
+	}
+
+	v.Check(validator.In(movie.ReleaseStatus, ReleaseStatuses...), "release_status",
+		fmt.Sprintf("must be one of %s", strings.Join(ReleaseStatuses, ", ")))
+
+	maxYear := int32(time.Now().Year())
+	if movie.ReleaseStatus == ReleaseReleased {
+		v.Check(movie.Year <= maxYear, "year", "must not be in the future for a released movie")
+	} else {
+		v.Check(movie.Year <= maxYear+maxFutureYears, "year",
+			fmt.Sprintf("must not be more than %d years in the future", maxFutureYears))
+	}
 
 	// Check movie.Runtime
 	v.Check(movie.Runtime != 0, "runtime", "must be provided")
 	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
 
+	// Normalise movie.Genres against the genre taxonomy, if there is one.
+	if genres != nil && movie.Genres != nil {
+		normalised, unknown := genres.Normalise(movie.Genres)
+		if len(unknown) > 0 {
+			v.AddError("genres", fmt.Sprintf("contains unknown genre %q", unknown[0]))
+		}
+		movie.Genres = append([]string{}, normalised...)
+	}
+
 	// Check movie.Genres
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
 
+	// Check the descriptive metadata.
+	v.Check(len(movie.Synopsis) <= 10000, "synopsis", "must not be more than 10000 bytes long")
+	v.Check(len(movie.OriginalTitle) <= 500, "original_title", "must not be more than 500 bytes long")
+
+	v.Check(len(movie.Languages) <= 20, "languages", "must not contain more than 20 languages")
+	v.Check(validator.Unique(movie.Languages), "languages", "must not contain duplicate values")
+	for _, language := range movie.Languages {
+		v.Check(validator.Matches(language, LanguageRX), "languages",
+			fmt.Sprintf("%q is not a valid ISO 639 language code", language))
+	}
+
+	for country, certification := range movie.Certifications {
+		v.Check(validator.Matches(country, CountryRX), "certifications",
+			fmt.Sprintf("%q is not a valid ISO 3166-1 alpha-2 country code", country))
+		v.Check(certification != "", "certifications", fmt.Sprintf("certification for %q must be provided", country))
+		v.Check(len(certification) <= 20, "certifications",
+			fmt.Sprintf("certification for %q must not be more than 20 bytes long", country))
+	}
+
+	for country, date := range movie.ReleaseDates {
+		v.Check(validator.Matches(country, CountryRX), "release_dates",
+			fmt.Sprintf("%q is not a valid ISO 3166-1 alpha-2 country code", country))
+
+		_, err := time.Parse(ReleaseDateLayout, date)
+		v.Check(err == nil, "release_dates", fmt.Sprintf("release date for %q must be a date, e.g. 2024-03-01", country))
+	}
+
+	// Check the external IDs. These are optional, and the zero value means that there isn't one.
+	v.Check(movie.IMDbID == "" || validator.Matches(movie.IMDbID, IMDbIDRX), "imdb_id", "must be a valid IMDb title ID")
+	v.Check(movie.TMDBID >= 0, "tmdb_id", "must be a positive integer")
+}
+
+// ValidateMovieFilters runs validation checks on the range and genre filters of MovieFilters.
+func ValidateMovieFilters(v *validator.Validator, mf MovieFilters) {
+	// The year ranges can include the years of unreleased movies.
+	maxYear := int32(time.Now().Year()) + maxFutureYears
+
+	v.Check(mf.ReleaseStatus == "" || validator.In(mf.ReleaseStatus, ReleaseStatuses...), "release_status",
+		fmt.Sprintf("must be one of %s", strings.Join(ReleaseStatuses, ", ")))
+
+	v.Check(mf.YearMin == 0 || (mf.YearMin >= 1888 && mf.YearMin <= maxYear), "year_min",
+		fmt.Sprintf("must be between 1888 and %d", maxYear))
+	v.Check(mf.YearMax == 0 || (mf.YearMax >= 1888 && mf.YearMax <= maxYear), "year_max",
+		fmt.Sprintf("must be between 1888 and %d", maxYear))
+	v.Check(mf.YearMin == 0 || mf.YearMax == 0 || mf.YearMin <= mf.YearMax, "year_max",
+		"must not be less than year_min")
+
+	v.Check(mf.RuntimeMin >= 0, "runtime_min", "must be a positive integer")
+	v.Check(mf.RuntimeMax >= 0, "runtime_max", "must be a positive integer")
+	v.Check(mf.RuntimeMin == 0 || mf.RuntimeMax == 0 || mf.RuntimeMin <= mf.RuntimeMax, "runtime_max",
+		"must not be less than runtime_min")
+
+	v.Check(mf.CreatedAfter.IsZero() || mf.CreatedBefore.IsZero() || mf.CreatedAfter.Before(mf.CreatedBefore),
+		"created_before", "must be after created_after")
+
+	v.Check(len(mf.GenresAny) <= 20, "genres_any", "must not contain more than 20 genres")
+	v.Check(len(mf.GenresNone) <= 20, "genres_none", "must not contain more than 20 genres")
+
+	for _, genre := range mf.GenresNone {
+		v.Check(!validator.In(genre, mf.Genres...), "genres_none", fmt.Sprintf("%q is also in genres", genre))
+		v.Check(!validator.In(genre, mf.GenresAny...), "genres_none", fmt.Sprintf("%q is also in genres_any", genre))
+	}
 }
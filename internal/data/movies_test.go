@@ -0,0 +1,54 @@
+package data
+
+import "testing"
+
+// TestGetAllUnfiltered checks that listing and counting movies without any filters returns
+// movies, rather than the NULL slice filters excluding every row.
+func TestGetAllUnfiltered(t *testing.T) {
+	models := NewModels(newTestDB(t))
+
+	movie := &Movie{
+		Title:         "Alien",
+		Year:          1979,
+		Runtime:       117,
+		Genres:        []string{"horror"},
+		Languages:     []string{"en"},
+		ReleaseStatus: ReleaseReleased,
+	}
+
+	err := models.Movies.Insert(movie, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := models.Movies.Delete(movie.ID, movie.Version, 0); err != nil {
+			t.Error(err)
+		}
+	})
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafeList: []string{"id"}}
+
+	movies, _, err := models.Movies.GetAll(NewMovieFilters(), filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) == 0 {
+		t.Error("GetAll found no movies")
+	}
+
+	movies, _, _, err = models.Movies.GetAllAfter(NewMovieFilters(), filters, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) == 0 {
+		t.Error("GetAllAfter found no movies")
+	}
+
+	count, err := models.Movies.Count(NewMovieFilters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count == 0 {
+		t.Error("Count found no movies")
+	}
+}
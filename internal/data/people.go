@@ -0,0 +1,211 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// Person type whose fields describe a person who has worked on movies, such as a director,
+// writer or actor. The BirthYear field is optional.
+type Person struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	BirthYear *int32    `json:"birth_year,omitempty"`
+	Biography string    `json:"biography,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+// PersonModel struct wraps a sql.DB connection pool and allows us to work with the Person struct
+// type and the people table in our database.
+type PersonModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// Insert inserts a new record in the people table.
+func (m PersonModel) Insert(person *Person) error {
+	query := `
+		INSERT INTO people (name, birth_year, biography)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version
+		`
+
+	args := []interface{}{person.Name, person.BirthYear, person.Biography}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+// Get fetches a record from the people table.
+func (m PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, birth_year, biography, version
+		FROM people
+		WHERE id = $1
+		`
+
+	var person Person
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.BirthYear,
+		&person.Biography,
+		&person.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &person, nil
+}
+
+// Update updates a specific record in the people table, using the version number for optimistic
+// concurrency control.
+func (m PersonModel) Update(person *Person) error {
+	query := `
+		UPDATE people
+		SET name = $1, birth_year = $2, biography = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+		`
+
+	args := []interface{}{person.Name, person.BirthYear, person.Biography, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes a specific record in the people table. Their credits are removed by the
+// ON DELETE CASCADE constraint on the credits table.
+func (m PersonModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM people
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll returns a paginated list of people, optionally filtered by name.
+func (m PersonModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, birth_year, biography, version
+		FROM people
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s, id ASC
+		LIMIT $2 OFFSET $3`,
+		filters.orderBy(""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(
+			&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&person.BirthYear,
+			&person.Biography,
+			&person.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return people, metadata, nil
+}
+
+// ValidatePerson runs validation checks on the Person type.
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if person.BirthYear != nil {
+		v.Check(*person.BirthYear >= 1800, "birth_year", "must be greater than 1800")
+		v.Check(*person.BirthYear <= int32(time.Now().Year()), "birth_year", "must not be in the future")
+	}
+
+	v.Check(len(person.Biography) <= 10_000, "biography", "must not be more than 10000 bytes long")
+}
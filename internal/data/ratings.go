@@ -0,0 +1,209 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// Rating type whose fields describe a single user's rating (and optional review) of a movie.
+// Each user can only have one rating per movie.
+type Rating struct {
+	MovieID   int64     `json:"movie_id"`
+	UserID    int64     `json:"user_id"`
+	UserName  string    `json:"user_name,omitempty"`
+	Rating    int32     `json:"rating"`
+	Review    string    `json:"review,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"version"`
+}
+
+// RatingModel struct wraps a sql.DB connection pool and allows us to work with the Rating struct
+// type and the ratings table in our database.
+type RatingModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// Upsert inserts a rating for a movie, or updates the existing rating if the user has already
+// rated the movie. It returns true if a new rating was created. Note, the average rating and
+// rating count on the movies table are kept up-to-date by a trigger on the ratings table.
+func (m RatingModel) Upsert(rating *Rating) (bool, error) {
+	query := `
+		INSERT INTO ratings (user_id, movie_id, rating, review)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, movie_id) DO UPDATE
+		SET rating = EXCLUDED.rating, review = EXCLUDED.review, updated_at = NOW(),
+			version = ratings.version + 1
+		RETURNING created_at, updated_at, version
+		`
+
+	args := []interface{}{rating.UserID, rating.MovieID, rating.Rating, rating.Review}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// If the movie doesn't exist (or has just been deleted) then the insert will violate the
+	// foreign key constraint on the movie_id column, so we return ErrRecordNotFound instead.
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&rating.CreatedAt, &rating.UpdatedAt, &rating.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "ratings" violates foreign key constraint "ratings_movie_id_fkey"`:
+			return false, ErrRecordNotFound
+		default:
+			return false, err
+		}
+	}
+
+	// A freshly inserted rating always starts at version 1.
+	return rating.Version == 1, nil
+}
+
+// Get returns the rating that a specific user has given a specific movie.
+func (m RatingModel) Get(movieID, userID int64) (*Rating, error) {
+	if movieID < 1 || userID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ratings.movie_id, ratings.user_id, users.name, ratings.rating, ratings.review,
+			ratings.created_at, ratings.updated_at, ratings.version
+		FROM ratings
+			INNER JOIN users ON users.id = ratings.user_id
+		WHERE ratings.movie_id = $1 AND ratings.user_id = $2
+		`
+
+	var rating Rating
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(
+		&rating.MovieID,
+		&rating.UserID,
+		&rating.UserName,
+		&rating.Rating,
+		&rating.Review,
+		&rating.CreatedAt,
+		&rating.UpdatedAt,
+		&rating.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rating, nil
+}
+
+// Delete removes the rating that a specific user has given a specific movie.
+func (m RatingModel) Delete(movieID, userID int64) error {
+	if movieID < 1 || userID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM ratings
+		WHERE movie_id = $1 AND user_id = $2
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForMovie returns a paginated list of the ratings for a specific movie. If reviewsOnly is
+// true then ratings without a written review are left out.
+func (m RatingModel) GetAllForMovie(movieID int64, reviewsOnly bool, filters Filters) ([]*Rating, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), ratings.movie_id, ratings.user_id, users.name, ratings.rating,
+			ratings.review, ratings.created_at, ratings.updated_at, ratings.version
+		FROM ratings
+			INNER JOIN users ON users.id = ratings.user_id
+		WHERE ratings.movie_id = $1
+		AND (ratings.review <> '' OR NOT $2)
+		ORDER BY %s, ratings.user_id ASC
+		LIMIT $3 OFFSET $4`,
+		filters.orderBy("ratings."))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{movieID, reviewsOnly, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	ratings := []*Rating{}
+
+	for rows.Next() {
+		var rating Rating
+
+		err := rows.Scan(
+			&totalRecords,
+			&rating.MovieID,
+			&rating.UserID,
+			&rating.UserName,
+			&rating.Rating,
+			&rating.Review,
+			&rating.CreatedAt,
+			&rating.UpdatedAt,
+			&rating.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		ratings = append(ratings, &rating)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return ratings, metadata, nil
+}
+
+// ValidateRating runs validation checks on the Rating type.
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.Check(rating.Rating != 0, "rating", "must be provided")
+	v.Check(rating.Rating >= 1 && rating.Rating <= 10, "rating", "must be between 1 and 10")
+
+	v.Check(len(rating.Review) <= 10_000, "review", "must not be more than 10000 bytes long")
+}
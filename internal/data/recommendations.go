@@ -0,0 +1,150 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// similarMoviesQuery ranks the other movies by their similarity to the movie with ID $1. The
+// score adds up the share of the movie's genres that they have in common (weighted most heavily),
+// how close their release years are, the trigram similarity of their titles, and how many users
+// liked both movies (a rating of 7 or more), on a logarithmic scale. Movies with nothing in
+// common get a score of zero and are left out.
+const similarMoviesQuery = `
+	WITH source AS (
+		SELECT id, title, year, genres FROM movies WHERE id = $1
+	), co_ratings AS (
+		SELECT other.movie_id, count(*) AS shared
+		FROM ratings AS liked
+			INNER JOIN ratings AS other ON other.user_id = liked.user_id AND other.movie_id <> liked.movie_id
+		WHERE liked.movie_id = $1
+		AND liked.rating >= 7
+		AND other.rating >= 7
+		GROUP BY other.movie_id
+	)
+	SELECT count(*) OVER(), ` + movieColumns + `, score
+	FROM (
+		SELECT movies.*, (
+			3 * cardinality(ARRAY(SELECT unnest(movies.genres) INTERSECT SELECT unnest(source.genres)))::FLOAT8
+				/ GREATEST(cardinality(source.genres), 1)
+			+ CASE WHEN movies.year = 0 OR source.year = 0 THEN 0
+				ELSE 1 / (1 + abs(movies.year - source.year) / 5.0) END
+			+ similarity(lower(movies.title), lower(source.title))
+			+ COALESCE(ln(1 + co_ratings.shared), 0)
+		)::FLOAT8 AS score
+		FROM movies
+			CROSS JOIN source
+			LEFT JOIN co_ratings ON co_ratings.movie_id = movies.id
+		WHERE movies.id <> source.id
+	) AS movies
+	WHERE score > 0
+	ORDER BY score DESC, id ASC
+	LIMIT $2 OFFSET $3`
+
+// recommendationsQuery ranks movies for the user with ID $1, based on the movies they have rated
+// or added to one of their lists. Each of those seed movies gets a weight: ratings of 6 or more
+// count in favour of a movie and ratings of 5 or less count against it, and a listed movie counts
+// as a rating of 8. A candidate movie then scores for the (normalised) weights of its genres, for
+// being liked by users who also liked the seed movies, and for its own average rating, so that
+// users with no ratings or lists get the best rated movies. Movies the user has already rated or
+// listed are left out.
+const recommendationsQuery = `
+	WITH seen AS (
+		SELECT movie_id, rating - 5.5 AS weight FROM ratings WHERE user_id = $1
+		UNION ALL
+		SELECT list_entries.movie_id, 2.5
+		FROM list_entries
+			INNER JOIN lists ON lists.id = list_entries.list_id
+		WHERE lists.user_id = $1
+	), seeds AS (
+		SELECT movie_id, sum(weight) AS weight FROM seen GROUP BY movie_id
+	), genre_weights AS (
+		SELECT genre.name AS genre, sum(seeds.weight) AS weight
+		FROM seeds
+			INNER JOIN movies ON movies.id = seeds.movie_id
+			CROSS JOIN LATERAL unnest(movies.genres) AS genre(name)
+		GROUP BY genre.name
+	), genre_scores AS (
+		SELECT movies.id AS movie_id,
+			sum(genre_weights.weight) / (SELECT NULLIF(max(abs(weight)), 0) FROM genre_weights) AS score
+		FROM movies
+			CROSS JOIN LATERAL unnest(movies.genres) AS genre(name)
+			INNER JOIN genre_weights ON genre_weights.genre = genre.name
+		GROUP BY movies.id
+	), co_ratings AS (
+		SELECT other.movie_id, sum(seeds.weight) AS weight
+		FROM seeds
+			INNER JOIN ratings AS peer ON peer.movie_id = seeds.movie_id AND peer.user_id <> $1
+			INNER JOIN ratings AS other ON other.user_id = peer.user_id AND other.movie_id <> peer.movie_id
+		WHERE seeds.weight > 0
+		AND peer.rating >= 7
+		AND other.rating >= 7
+		GROUP BY other.movie_id
+	)
+	SELECT count(*) OVER(), ` + movieColumns + `, score
+	FROM (
+		SELECT movies.*, (
+			2 * COALESCE(genre_scores.score, 0)
+			+ COALESCE(ln(1 + co_ratings.weight), 0)
+			+ movies.average_rating / 10
+		)::FLOAT8 AS score
+		FROM movies
+			LEFT JOIN genre_scores ON genre_scores.movie_id = movies.id
+			LEFT JOIN co_ratings ON co_ratings.movie_id = movies.id
+		WHERE movies.id NOT IN (SELECT movie_id FROM seeds)
+	) AS movies
+	WHERE score > 0
+	ORDER BY score DESC, id ASC
+	LIMIT $2 OFFSET $3`
+
+// GetSimilar returns a paginated list of the movies most similar to the movie with the given
+// ID, with the highest Score first. See similarMoviesQuery for how they are scored.
+func (m MovieModel) GetSimilar(id int64, filters Filters) ([]*Movie, Metadata, error) {
+	return m.getScored(similarMoviesQuery, id, filters)
+}
+
+// GetRecommendations returns a paginated list of the movies recommended for the user with the
+// given ID, with the highest Score first. See recommendationsQuery for how they are scored.
+func (m MovieModel) GetRecommendations(userID int64, filters Filters) ([]*Movie, Metadata, error) {
+	return m.getScored(recommendationsQuery, userID, filters)
+}
+
+// getScored runs a query which selects a count of all the rows, the movieColumns and a score,
+// with the ID, limit and offset as its parameters, and returns the movies and their metadata.
+func (m MovieModel) getScored(query string, id int64, filters Filters) ([]*Movie, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		dest := append([]interface{}{&totalRecords}, movie.scanDest()...)
+		err := rows.Scan(append(dest, &movie.Score)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// The release statuses of a movie. Only released movies must have a year which isn't in the
+// future.
+const (
+	ReleaseAnnounced    = "announced"
+	ReleaseInProduction = "in_production"
+	ReleaseReleased     = "released"
+)
+
+// ReleaseStatuses lists the valid release statuses, in the order a movie moves through them.
+var ReleaseStatuses = []string{ReleaseAnnounced, ReleaseInProduction, ReleaseReleased}
+
+// ReleaseDateLayout is the layout of the dates in ReleaseDates.
+const ReleaseDateLayout = "2006-01-02"
+
+// maxFutureYears is how many years ahead an unreleased movie's year can be.
+const maxFutureYears = 10
+
+// ReleaseDates maps ISO 3166-1 alpha-2 country codes to the date a movie is (or was) released
+// in that country, e.g. {"US": "2024-03-01"}. It is stored as a JSONB column.
+type ReleaseDates map[string]string
+
+// Value implements the driver.Valuer interface, so that ReleaseDates can be written to the
+// database as JSON.
+func (d ReleaseDates) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan implements the sql.Scanner interface, so that ReleaseDates can be read from a JSONB
+// column.
+func (d *ReleaseDates) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, d)
+	case string:
+		return json.Unmarshal([]byte(src), d)
+	case nil:
+		*d = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into ReleaseDates", src)
+	}
+}
+
+// PromoteReleased marks up to limit unreleased movies as released, if they have been released
+// in at least one country on or before the given date. Movies whose year is still in the future
+// are left until that year. Each promotion is recorded as an update revision made by the system
+// rather than a user. It returns the promoted movies.
+func (m MovieModel) PromoteReleased(date time.Time, limit int) ([]*Movie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackTx(tx, m.ErrorLog)
+
+	movies, err := m.getDueForRelease(ctx, tx, date, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, movie := range movies {
+		movie.ReleaseStatus = ReleaseReleased
+
+		err = m.updateTx(ctx, tx, movie, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// getDueForRelease fetches up to limit movies which are due to be promoted to released on the
+// given date, as part of the provided transaction, locking their rows until the transaction
+// ends. Rows which are already locked are skipped, so that they're left for the next run.
+func (m MovieModel) getDueForRelease(ctx context.Context, tx *sql.Tx, date time.Time, limit int) ([]*Movie, error) {
+	query := `
+		SELECT ` + movieColumns + `
+		FROM movies
+		WHERE release_status <> 'released'
+			AND year <= $2
+			AND EXISTS (SELECT 1 FROM jsonb_each_text(release_dates) AS dates WHERE dates.value::DATE <= $1)
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+		`
+
+	rows, err := tx.QueryContext(ctx, query, date.Format(ReleaseDateLayout), date.Year(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movie.scanDest()...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+func TestValidateMovieReleaseStatus(t *testing.T) {
+	thisYear := int32(time.Now().Year())
+
+	tests := []struct {
+		name         string
+		status       string
+		year         int32
+		releaseDates ReleaseDates
+		wantErrors   []string
+	}{
+		{"Released this year", ReleaseReleased, thisYear, nil, nil},
+		{"Defaults to released", "", thisYear + 1, nil, []string{"year"}},
+		{"Released in the future", ReleaseReleased, thisYear + 1, nil, []string{"year"}},
+		{"Announced for next year", ReleaseAnnounced, thisYear + 1, nil, nil},
+		{"In production too far ahead", ReleaseInProduction, thisYear + 11, nil, []string{"year"}},
+		{"Unknown status", "rumoured", thisYear, nil, []string{"release_status"}},
+		{"Release dates", ReleaseAnnounced, thisYear + 1, ReleaseDates{"US": "2030-03-01"}, nil},
+		{"Invalid country", ReleaseAnnounced, thisYear + 1, ReleaseDates{"usa": "2030-03-01"}, []string{"release_dates"}},
+		{"Invalid date", ReleaseAnnounced, thisYear + 1, ReleaseDates{"US": "March 2030"}, []string{"release_dates"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := &Movie{
+				Title:         "Untitled",
+				Year:          tt.year,
+				Runtime:       120,
+				Genres:        []string{"drama"},
+				ReleaseStatus: tt.status,
+				ReleaseDates:  tt.releaseDates,
+			}
+
+			v := validator.New()
+			ValidateMovie(v, movie, nil)
+
+			if len(v.Errors) != len(tt.wantErrors) {
+				t.Fatalf("got errors %v; want errors for %q", v.Errors, tt.wantErrors)
+			}
+
+			for _, key := range tt.wantErrors {
+				if _, ok := v.Errors[key]; !ok {
+					t.Errorf("got errors %v; want an error for %q", v.Errors, key)
+				}
+			}
+		})
+	}
+}
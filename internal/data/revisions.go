@@ -0,0 +1,276 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Define the actions which can be recorded against a movie revision.
+const (
+	RevisionInsert = "insert"
+	RevisionUpdate = "update"
+	RevisionDelete = "delete"
+)
+
+// FieldChange holds the old and new value of a single movie field for a revision.
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// MovieRevision represents a single captured change to a movie record. The Changes field holds
+// the field-level diff and the Snapshot field holds the state of the movie's editable fields as
+// of this revision, which is what we restore from when reverting.
+type MovieRevision struct {
+	ID        int64           `json:"id"`
+	MovieID   int64           `json:"movie_id"`
+	Version   int32           `json:"version"`
+	Action    string          `json:"action"`
+	UserID    *int64          `json:"user_id"`
+	Changes   json.RawMessage `json:"changes"`
+	Snapshot  json.RawMessage `json:"snapshot"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// MovieRevisionModel struct wraps a sql.DB connection pool and allows us to work with the
+// MovieRevision struct type and the movie_revisions table in our database.
+type MovieRevisionModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// revisionFields returns the editable fields of a movie keyed by their JSON names. These are
+// the fields that are diffed and snapshotted for each revision.
+func (movie *Movie) revisionFields() map[string]interface{} {
+	return map[string]interface{}{
+		"title":          movie.Title,
+		"year":           movie.Year,
+		"runtime":        movie.Runtime,
+		"genres":         movie.Genres,
+		"synopsis":       movie.Synopsis,
+		"original_title": movie.OriginalTitle,
+		"languages":      movie.Languages,
+		"certifications": movie.Certifications,
+		"release_status": movie.ReleaseStatus,
+		"release_dates":  movie.ReleaseDates,
+		"imdb_id":        movie.IMDbID,
+		"tmdb_id":        movie.TMDBID,
+	}
+}
+
+// diffMovies returns the field-level changes between two states of a movie. A nil before movie
+// represents an insert, and a nil after movie represents a delete.
+func diffMovies(before, after *Movie) map[string]FieldChange {
+	var oldFields, newFields map[string]interface{}
+	if before != nil {
+		oldFields = before.revisionFields()
+	}
+	if after != nil {
+		newFields = after.revisionFields()
+	}
+
+	changes := make(map[string]FieldChange)
+
+	for _, fields := range []map[string]interface{}{oldFields, newFields} {
+		for key := range fields {
+			if _, seen := changes[key]; seen {
+				continue
+			}
+
+			if before != nil && after != nil && reflect.DeepEqual(oldFields[key], newFields[key]) {
+				continue
+			}
+
+			changes[key] = FieldChange{Old: oldFields[key], New: newFields[key]}
+		}
+	}
+
+	return changes
+}
+
+// insertRevision records a revision for a movie as part of the provided transaction. The
+// revision takes the version of the after state, or of the before state for a delete.
+func insertRevision(ctx context.Context, tx *sql.Tx, action string, userID int64, before, after *Movie) error {
+	current := after
+	if action == RevisionDelete {
+		current = before
+	}
+
+	changes, err := json.Marshal(diffMovies(before, after))
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := json.Marshal(current.revisionFields())
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO movie_revisions (movie_id, version, action, user_id, changes, snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		`
+
+	// Revisions made by the system rather than a user (i.e., a zero user ID) are stored with a
+	// NULL user_id.
+	args := []interface{}{
+		current.ID,
+		current.Version,
+		action,
+		sql.NullInt64{Int64: userID, Valid: userID > 0},
+		changes,
+		snapshot,
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Get returns the most recent non-delete revision of a movie for a specific version.
+func (m MovieRevisionModel) Get(movieID int64, version int32) (*MovieRevision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, movie_id, version, action, user_id, changes, snapshot, created_at
+		FROM movie_revisions
+		WHERE movie_id = $1 AND version = $2 AND action <> 'delete'
+		ORDER BY id DESC
+		LIMIT 1
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revision MovieRevision
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, version).Scan(
+		&revision.ID,
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Action,
+		&revision.UserID,
+		&revision.Changes,
+		&revision.Snapshot,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+// GetAll returns a paginated list of the revisions for a specific movie. Note that revisions
+// are kept after a movie is deleted, so this doesn't check that the movie still exists.
+func (m MovieRevisionModel) GetAll(movieID int64, filters Filters) ([]*MovieRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, movie_id, version, action, user_id, changes, snapshot, created_at
+		FROM movie_revisions
+		WHERE movie_id = $1
+		ORDER BY %s, id %s
+		LIMIT $2 OFFSET $3`,
+		filters.orderBy(""), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	revisions := []*MovieRevision{}
+
+	for rows.Next() {
+		var revision MovieRevision
+
+		err := rows.Scan(
+			&totalRecords,
+			&revision.ID,
+			&revision.MovieID,
+			&revision.Version,
+			&revision.Action,
+			&revision.UserID,
+			&revision.Changes,
+			&revision.Snapshot,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+
+// Restore copies the editable fields captured in the revision snapshot onto the provided movie.
+// The movie ID and version are left untouched so that the restored movie can be saved through
+// the usual optimistic concurrency control in MovieModel.Update.
+func (r *MovieRevision) Restore(movie *Movie) error {
+	var snapshot Movie
+
+	err := json.Unmarshal(r.Snapshot, &snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Snapshots taken before a field existed won't contain it, so only restore the fields which
+	// are present in the snapshot and leave the others as they are.
+	var present map[string]json.RawMessage
+
+	err = json.Unmarshal(r.Snapshot, &present)
+	if err != nil {
+		return err
+	}
+
+	restore := map[string]func(){
+		"title":          func() { movie.Title = snapshot.Title },
+		"year":           func() { movie.Year = snapshot.Year },
+		"runtime":        func() { movie.Runtime = snapshot.Runtime },
+		"genres":         func() { movie.Genres = snapshot.Genres },
+		"synopsis":       func() { movie.Synopsis = snapshot.Synopsis },
+		"original_title": func() { movie.OriginalTitle = snapshot.OriginalTitle },
+		"languages":      func() { movie.Languages = snapshot.Languages },
+		"certifications": func() { movie.Certifications = snapshot.Certifications },
+		"release_status": func() { movie.ReleaseStatus = snapshot.ReleaseStatus },
+		"release_dates":  func() { movie.ReleaseDates = snapshot.ReleaseDates },
+		"imdb_id":        func() { movie.IMDbID = snapshot.IMDbID },
+		"tmdb_id":        func() { movie.TMDBID = snapshot.TMDBID },
+	}
+
+	for key, fn := range restore {
+		if _, ok := present[key]; ok {
+			fn()
+		}
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package data
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -11,11 +14,108 @@ import (
 // successfully. This is used in our Runtime.UnmarshalJSON() method.
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+// Runtime is a movie runtime in whole minutes.
 type Runtime int32
 
+// RuntimeFormat is a representation of a Runtime in JSON output.
+type RuntimeFormat string
+
+// The runtime formats that clients can choose between. RuntimeMins is the default.
+const (
+	RuntimeMins    RuntimeFormat = "mins"    // "102 mins"
+	RuntimeMinutes RuntimeFormat = "minutes" // 102
+	RuntimeHM      RuntimeFormat = "hm"      // "1h 42m"
+	RuntimeISO8601 RuntimeFormat = "iso8601" // "PT1H42M"
+)
+
+// RuntimeFormats holds all of the runtime formats.
+var RuntimeFormats = []RuntimeFormat{RuntimeMins, RuntimeMinutes, RuntimeHM, RuntimeISO8601}
+
+var (
+	// runtimeMinsRX matches a number of minutes, such as "102", "102 mins" or "102 minutes".
+	runtimeMinsRX = regexp.MustCompile(`^(\d+)\s*(?:m|mins?|minutes?)?$`)
+	// runtimeHMRX matches hours and minutes, such as "1h 42m", "1h42m", "2 hours" or "1 hr 5 min".
+	runtimeHMRX = regexp.MustCompile(`^(\d+)\s*(?:h|hrs?|hours?)\s*(?:(\d+)\s*(?:m|mins?|minutes?))?$`)
+	// runtimeISO8601RX matches an ISO 8601 duration in hours, minutes and seconds, such as
+	// "PT102M" or "PT1H42M".
+	runtimeISO8601RX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+)
+
+// ParseRuntime parses a runtime written as a number of minutes ("102" or "102 mins"), in hours
+// and minutes ("1h 42m"), or as an ISO 8601 duration ("PT1H42M"). ISO 8601 durations must be a
+// whole number of minutes. It returns ErrInvalidRuntimeFormat if the runtime can't be parsed.
+func ParseRuntime(s string) (Runtime, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	var hours, minutes, seconds string
+
+	if m := runtimeMinsRX.FindStringSubmatch(s); m != nil {
+		minutes = m[1]
+	} else if m := runtimeHMRX.FindStringSubmatch(s); m != nil {
+		hours, minutes = m[1], m[2]
+	} else if m := runtimeISO8601RX.FindStringSubmatch(strings.ToUpper(s)); m != nil && s != "pt" {
+		hours, minutes, seconds = m[1], m[2], m[3]
+	} else {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	var total int64
+
+	for _, part := range []struct {
+		value string
+		scale int64
+	}{{hours, 3600}, {minutes, 60}, {seconds, 1}} {
+		if part.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(part.value, 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+
+		total += n * part.scale
+	}
+
+	if total%60 != 0 || total/60 > math.MaxInt32 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return Runtime(total / 60), nil
+}
+
+// Format returns the runtime in the given format, as a value which can be encoded as JSON.
+func (r Runtime) Format(format RuntimeFormat) interface{} {
+	switch format {
+	case RuntimeMinutes:
+		return int32(r)
+	case RuntimeHM:
+		switch {
+		case r < 60:
+			return fmt.Sprintf("%dm", r)
+		case r%60 == 0:
+			return fmt.Sprintf("%dh", r/60)
+		default:
+			return fmt.Sprintf("%dh %dm", r/60, r%60)
+		}
+	case RuntimeISO8601:
+		switch {
+		case r < 60:
+			return fmt.Sprintf("PT%dM", r)
+		case r%60 == 0:
+			return fmt.Sprintf("PT%dH", r/60)
+		default:
+			return fmt.Sprintf("PT%dH%dM", r/60, r%60)
+		}
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
 // MarshalJSON method on the Runtime type so that it satisfies the
 // json.Marshaler interface. This should return the JSON-encoded string for the movie
-// runtime in the format "<runtime> mins".
+// runtime in the format "<runtime> mins". Other formats can be chosen for a movie with
+// Movie.SetRuntimeFormat.
 func (r Runtime) MarshalJSON() ([]byte, error) {
 	// Generate a string containing the movie runtime in the required format
 	jsonValue := fmt.Sprintf("%d mins", r)
@@ -33,36 +133,39 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 // receiver (our Runtime type), we must use a pointer receiver for this to work
 // correctly. Otherwise, we will only be modifying a copy (which is then discarded when
 // this method returns).
+//
+// The runtime can be a JSON number of minutes, or a string in any of the formats accepted by
+// ParseRuntime.
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	// We expect that the incoming JSON value will be a string in the format
-	// "<runtime> mins", and the first thing we need to do is remove the surrounding
-	// double-quotes from this string. If we can't unquote it, then we return the
-	// ErrInvalidRuntimeFormat error.
-	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
-	if err != nil {
-		return ErrInvalidRuntimeFormat
-	}
+	// If the value isn't a JSON string, then it must be a whole number of minutes.
+	if !strings.HasPrefix(string(jsonValue), `"`) {
+		var i int32
 
-	// Split the string to isolate the part containing the number.
-	parts := strings.Split(unquotedJSONValue, " ")
+		err := json.Unmarshal(jsonValue, &i)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
 
-	// Sanity check the parts of the string to make sure it was in the expected format.
-	// If it wasn't, we return the ErrInvalidRuntimeFormat error again.
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFormat
+		*r = Runtime(i)
+		return nil
 	}
 
-	// Otherwise, parse the string containing the number into an int32. Again, if this
-	// fails return the ErrInvalidRuntimeFormat error.
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+	// Otherwise remove the surrounding double-quotes from the string. If we can't unquote it,
+	// then we return the ErrInvalidRuntimeFormat error.
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
 	if err != nil {
 		return ErrInvalidRuntimeFormat
 	}
 
-	// Convert the int32 to a Runtime type and assign this to the receiver. Note that we
-	// use the * operator to deference the receiver (which is a pointer to a Runtime
-	// type) in order to set the underlying value of the pointer.
-	*r = Runtime(i)
+	// Parse the string, and assign the result to the receiver. Note that we use the * operator
+	// to deference the receiver (which is a pointer to a Runtime type) in order to set the
+	// underlying value of the pointer.
+	runtime, err := ParseRuntime(unquotedJSONValue)
+	if err != nil {
+		return err
+	}
+
+	*r = runtime
 
 	return nil
 }
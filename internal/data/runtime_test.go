@@ -0,0 +1,101 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Runtime
+		wantErr error
+	}{
+		{`"102 mins"`, 102, nil},
+		{`"1 min"`, 1, nil},
+		{`"102 minutes"`, 102, nil},
+		{`"102"`, 102, nil},
+		{`102`, 102, nil},
+		{`"1h 42m"`, 102, nil},
+		{`"1h42m"`, 102, nil},
+		{`"2h"`, 120, nil},
+		{`"42m"`, 42, nil},
+		{`"1 hour 5 minutes"`, 65, nil},
+		{`"PT102M"`, 102, nil},
+		{`"PT1H42M"`, 102, nil},
+		{`"pt1h42m"`, 102, nil},
+		{`"PT2H"`, 120, nil},
+		{`"PT1H0M120S"`, 62, nil},
+		{`"PT90S"`, 0, ErrInvalidRuntimeFormat},
+		{`"PT"`, 0, ErrInvalidRuntimeFormat},
+		{`"P1D"`, 0, ErrInvalidRuntimeFormat},
+		{`"102 secs"`, 0, ErrInvalidRuntimeFormat},
+		{`"1h 42m 10s"`, 0, ErrInvalidRuntimeFormat},
+		{`"-5 mins"`, 0, ErrInvalidRuntimeFormat},
+		{`"99999999999 mins"`, 0, ErrInvalidRuntimeFormat},
+		{`102.5`, 0, ErrInvalidRuntimeFormat},
+		{`""`, 0, ErrInvalidRuntimeFormat},
+		{`true`, 0, ErrInvalidRuntimeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var got Runtime
+
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("unmarshal %s returned error %v; want %v", tt.json, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unmarshal %s returned error %v", tt.json, err)
+			}
+			if got != tt.want {
+				t.Errorf("unmarshal %s = %d; want %d", tt.json, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovieRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		format RuntimeFormat
+		want   string
+	}{
+		{"", `"102 mins"`},
+		{RuntimeMins, `"102 mins"`},
+		{RuntimeMinutes, `102`},
+		{RuntimeHM, `"1h 42m"`},
+		{RuntimeISO8601, `"PT1H42M"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			movie := &Movie{ID: 1, Title: "Casablanca", Runtime: 102}
+			movie.SetRuntimeFormat(tt.format)
+
+			js, err := json.Marshal(movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]json.RawMessage
+
+			err = json.Unmarshal(js, &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got["runtime"]) != tt.want {
+				t.Errorf("runtime = %s; want %s", got["runtime"], tt.want)
+			}
+			if string(got["title"]) != `"Casablanca"` {
+				t.Errorf("title = %s; want %q", got["title"], "Casablanca")
+			}
+		})
+	}
+}
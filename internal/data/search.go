@@ -0,0 +1,294 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// searchConfigs holds the text search configurations that movie_search_config (see migration
+// 000015) can choose for a movie. The search condition below has a branch per configuration, so
+// that PostgreSQL can use the GIN index on search_vector whichever configuration a movie uses.
+var searchConfigs = []string{"simple", "danish", "dutch", "english", "finnish", "french", "german",
+	"hungarian", "italian", "norwegian", "portuguese", "russian", "spanish", "swedish", "turkish"}
+
+// searchCondition returns an SQL condition which matches movies against the tsquery text in the
+// placeholder parameter $n, parsing it with each movie's own text search configuration. An
+// empty parameter matches every movie.
+func searchCondition(n int) string {
+	branches := []string{fmt.Sprintf("$%d = ''", n)}
+
+	for _, config := range searchConfigs {
+		branches = append(branches, fmt.Sprintf(
+			"(search_config = '%[1]s'::REGCONFIG AND search_vector @@ to_tsquery('%[1]s', $%[2]d))", config, n))
+	}
+
+	return "(" + strings.Join(branches, "\n\t\tOR ") + ")"
+}
+
+// searchRank returns an SQL expression for the relevance of a movie to the tsquery text in the
+// placeholder parameter $n. Movies have a relevance of 0 when there is no search.
+func searchRank(n int) string {
+	return fmt.Sprintf("CASE WHEN $%[1]d = '' THEN 0 ELSE ts_rank(search_vector, to_tsquery(search_config, $%[1]d)) END", n)
+}
+
+// fuzzyCondition returns an SQL condition which matches movies whose normalised title contains
+// something similar to the text in the placeholder parameter $n, using the trigram index on
+// normalise_title(title). An empty parameter matches every movie.
+func fuzzyCondition(n int) string {
+	return fmt.Sprintf("($%[1]d = '' OR normalise_title($%[1]d) <%% normalise_title(title))", n)
+}
+
+// fuzzyRank returns an SQL expression for how closely a movie's title matches the text in the
+// placeholder parameter $n. Movies have a rank of 0 when there is no fuzzy match text.
+func fuzzyRank(n int) string {
+	return fmt.Sprintf("CASE WHEN $%[1]d = '' THEN 0 ELSE word_similarity(normalise_title($%[1]d), normalise_title(title)) END", n)
+}
+
+// SearchText returns the plain words of a search string typed by a user, without any of the
+// syntax understood by ParseSearchQuery and leaving out excluded words. It is used for fuzzy
+// matching, which works on text rather than a tsquery.
+func SearchText(s string) string {
+	var words []string
+
+	for _, token := range tokeniseSearch(s) {
+		if token == "OR" || token == "|" || strings.HasPrefix(token, "-") || strings.HasPrefix(token, "!") {
+			continue
+		}
+
+		words = append(words, strings.FieldsFunc(strings.ToLower(token), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ParseSearchQuery converts a search string typed by a user into the tsquery syntax accepted by
+// PostgreSQL's to_tsquery. Words are combined with AND, and the following syntax is supported:
+//
+//	"a new hope"   matches the words as a phrase
+//	star*          matches words starting with "star"
+//	-trek or !trek excludes movies matching "trek"
+//	alien OR aliens matches either word
+//
+// Punctuation within words is treated as a word separator, so "spider-man" is searched for as
+// the phrase "spider man". Anything which can't be parsed is ignored, so this never returns an
+// invalid query. It returns an empty string if there is nothing to search for.
+func ParseSearchQuery(s string) string {
+	var (
+		groups  [][]string // The query as a list of OR groups, which are combined with AND.
+		pending bool       // Whether the next item should join the previous OR group.
+	)
+
+	for _, token := range tokeniseSearch(s) {
+		if token == "OR" || token == "|" {
+			pending = len(groups) > 0
+			continue
+		}
+
+		item := searchItem(token)
+		if item == "" {
+			continue
+		}
+
+		if pending {
+			groups[len(groups)-1] = append(groups[len(groups)-1], item)
+		} else {
+			groups = append(groups, []string{item})
+		}
+
+		pending = false
+	}
+
+	parts := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			parts = append(parts, group[0])
+		} else {
+			parts = append(parts, "("+strings.Join(group, " | ")+")")
+		}
+	}
+
+	return strings.Join(parts, " & ")
+}
+
+// tokeniseSearch splits a search string into whitespace separated tokens, keeping quoted
+// phrases (along with any leading negation) together as a single token. An unterminated quote
+// runs to the end of the string.
+func tokeniseSearch(s string) []string {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			current.WriteRune(r)
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens
+}
+
+// searchItem converts a single token into tsquery syntax, returning an empty string if the
+// token doesn't contain any words.
+func searchItem(token string) string {
+	negated := false
+	if strings.HasPrefix(token, "-") || strings.HasPrefix(token, "!") {
+		negated = true
+		token = token[1:]
+	}
+
+	prefix := false
+	if !strings.HasPrefix(token, `"`) && strings.HasSuffix(token, "*") {
+		prefix = true
+		token = strings.TrimRight(token, "*")
+	}
+
+	// Split the token into words on anything that isn't a letter or a digit. This also strips
+	// out quotes and any characters which have a special meaning in tsquery syntax.
+	words := strings.FieldsFunc(token, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	for i, word := range words {
+		words[i] = "'" + strings.ToLower(word) + "'"
+	}
+
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+
+	item := strings.Join(words, " <-> ")
+	if len(words) > 1 {
+		item = "(" + item + ")"
+	}
+
+	if negated {
+		item = "!" + item
+	}
+
+	return item
+}
+
+// MovieCompletion is a title completion returned by MovieModel.Autocomplete.
+type MovieCompletion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+// Suggest returns up to limit distinct movie titles which are similar to the provided text,
+// most similar first. These are offered as "did you mean" suggestions when a search finds
+// nothing.
+func (m MovieModel) Suggest(text string, limit int) ([]string, error) {
+	query := `
+		SELECT title
+		FROM movies
+		WHERE normalise_title($1) <% normalise_title(title)
+		GROUP BY title
+		ORDER BY max(word_similarity(normalise_title($1), normalise_title(title))) DESC,
+			max(rating_count) DESC, title ASC
+		LIMIT $2
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, text, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	var suggestions []string
+
+	for rows.Next() {
+		var title string
+
+		err := rows.Scan(&title)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, title)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// Autocomplete returns up to limit movies whose title contains the provided text, for completing
+// a title as it is typed. Titles which start with the text come first, and then the most rated
+// movies.
+func (m MovieModel) Autocomplete(text string, limit int) ([]*MovieCompletion, error) {
+	query := `
+		SELECT id, title, year
+		FROM movies
+		WHERE lower(title) LIKE '%' || $1 || '%'
+		ORDER BY lower(title) LIKE $1 || '%' DESC, rating_count DESC, title ASC, id ASC
+		LIMIT $2
+		`
+
+	// Escape the LIKE wildcards in the text, so that they're matched literally.
+	text = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(text))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, text, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	completions := []*MovieCompletion{}
+
+	for rows.Next() {
+		var completion MovieCompletion
+
+		err := rows.Scan(&completion.ID, &completion.Title, &completion.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		completions = append(completions, &completion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return completions, nil
+}
@@ -0,0 +1,53 @@
+package data
+
+import "testing"
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Empty", "", ""},
+		{"Single word", "Alien", "'alien'"},
+		{"Words are combined with AND", "black panther", "'black' & 'panther'"},
+		{"Prefix", "star*", "'star':*"},
+		{"Phrase", `"a new hope"`, "('a' <-> 'new' <-> 'hope')"},
+		{"Negation", "star -trek", "'star' & !'trek'"},
+		{"Negated phrase", `star !"the clone wars"`, "'star' & !('the' <-> 'clone' <-> 'wars')"},
+		{"OR", "alien OR aliens", "('alien' | 'aliens')"},
+		{"OR binds tighter than AND", "the alien OR aliens", "'the' & ('alien' | 'aliens')"},
+		{"Hyphenated word", "spider-man", "('spider' <-> 'man')"},
+		{"Special characters are stripped", "a&b|c!(d):*'e'", "('a' <-> 'b' <-> 'c' <-> 'd' <-> 'e')"},
+		{"Dangling operators are ignored", "OR - * alien OR", "'alien'"},
+		{"Unterminated quote", `"the matrix`, "('the' <-> 'matrix')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseSearchQuery(tt.input)
+			if got != tt.want {
+				t.Errorf("ParseSearchQuery(%q) = %q; want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"godfahter", "godfahter"},
+		{`"The Godfahter" part*`, "the godfahter part"},
+		{"star -trek OR wars", "star wars"},
+		{"spider-man", "spider man"},
+	}
+
+	for _, tt := range tests {
+		got := SearchText(tt.input)
+		if got != tt.want {
+			t.Errorf("SearchText(%q) = %q; want %q", tt.input, got, tt.want)
+		}
+	}
+}
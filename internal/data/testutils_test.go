@@ -0,0 +1,29 @@
+package data
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+// newTestDB opens the database named by the GREENLIGHT_TEST_DB_DSN environment variable, which
+// must have every migration applied, and skips the test if it isn't set.
+func newTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("GREENLIGHT_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return db
+}
@@ -0,0 +1,205 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDuplicateMovieTitle is returned when a movie already has a title for the same language
+	// and region.
+	ErrDuplicateMovieTitle = errors.New("duplicate movie title")
+)
+
+// MovieTitle type whose fields describe an alternate title of a movie in a language, which is
+// an ISO 639 code, and optionally a region, which is an ISO 3166-1 alpha-2 country code.
+type MovieTitle struct {
+	ID       int64  `json:"id"`
+	MovieID  int64  `json:"movie_id"`
+	Language string `json:"language"`
+	Region   string `json:"region,omitempty"`
+	Title    string `json:"title"`
+}
+
+// Locale returns the language tag of the title, such as "fr" or "fr-CA".
+func (t *MovieTitle) Locale() string {
+	if t.Region == "" {
+		return t.Language
+	}
+	return t.Language + "-" + t.Region
+}
+
+// LocalisedTitle chooses the title that best matches a list of language tags (such as "fr-CA"),
+// in order of preference. For each tag in turn it looks for a title with the same language and
+// region, then a title for the language with no region, and then a title for the language in
+// any region. It returns nil if none of the titles match.
+func LocalisedTitle(titles []*MovieTitle, tags []string) *MovieTitle {
+	for _, tag := range tags {
+		language, region, _ := strings.Cut(tag, "-")
+		language = strings.ToLower(language)
+		region = strings.ToUpper(region)
+
+		var languageOnly, anyRegion *MovieTitle
+
+		for _, title := range titles {
+			if title.Language != language {
+				continue
+			}
+
+			switch {
+			case region != "" && title.Region == region:
+				return title
+			case title.Region == "" && languageOnly == nil:
+				languageOnly = title
+			case anyRegion == nil:
+				anyRegion = title
+			}
+		}
+
+		if languageOnly != nil {
+			return languageOnly
+		}
+		if anyRegion != nil {
+			return anyRegion
+		}
+	}
+
+	return nil
+}
+
+// MovieTitleModel struct wraps a sql.DB connection pool and allows us to work with the
+// MovieTitle struct type and the movie_titles table in our database.
+type MovieTitleModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// movieTitleError converts constraint violations on the movie_titles table into our own errors.
+func movieTitleError(err error) error {
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "movie_titles_movie_id_language_region_key"`:
+		return ErrDuplicateMovieTitle
+	case err.Error() == `pq: insert or update on table "movie_titles" violates foreign key constraint "movie_titles_movie_id_fkey"`:
+		return ErrRecordNotFound
+	default:
+		return err
+	}
+}
+
+// Insert inserts a new record in the movie_titles table. It returns ErrRecordNotFound if the
+// movie doesn't exist.
+func (m MovieTitleModel) Insert(title *MovieTitle) error {
+	query := `
+		INSERT INTO movie_titles (movie_id, language, region, title)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+		`
+
+	args := []interface{}{title.MovieID, title.Language, title.Region, title.Title}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&title.ID)
+	if err != nil {
+		return movieTitleError(err)
+	}
+
+	return nil
+}
+
+// Delete deletes a specific alternate title of a movie.
+func (m MovieTitleModel) Delete(movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_titles
+		WHERE movie_id = $1 AND id = $2
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForMovies returns the alternate titles for each of the provided movie IDs in a single
+// query, ordered by language and region, keyed by movie ID.
+func (m MovieTitleModel) GetAllForMovies(movieIDs ...int64) (map[int64][]*MovieTitle, error) {
+	titles := make(map[int64][]*MovieTitle)
+
+	if len(movieIDs) == 0 {
+		return titles, nil
+	}
+
+	query := `
+		SELECT id, movie_id, language, region, title
+		FROM movie_titles
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id, language, region, id
+		`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var title MovieTitle
+
+		err := rows.Scan(&title.ID, &title.MovieID, &title.Language, &title.Region, &title.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		titles[title.MovieID] = append(titles[title.MovieID], &title)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// ValidateMovieTitle runs validation checks on the MovieTitle type.
+func ValidateMovieTitle(v *validator.Validator, title *MovieTitle) {
+	v.Check(validator.Matches(title.Language, LanguageRX), "language", "must be an ISO 639 language code, e.g. fr")
+	v.Check(title.Region == "" || validator.Matches(title.Region, CountryRX), "region",
+		"must be an ISO 3166-1 alpha-2 country code, e.g. CA")
+
+	v.Check(title.Title != "", "title", "must be provided")
+	v.Check(len(title.Title) <= 500, "title", "must not be more than 500 bytes long")
+}
@@ -0,0 +1,86 @@
+package data
+
+import "testing"
+
+func TestLocalisedTitle(t *testing.T) {
+	titles := []*MovieTitle{
+		{ID: 1, Language: "fr", Title: "La Guerre des étoiles"},
+		{ID: 2, Language: "fr", Region: "CA", Title: "La Guerre des étoiles (Québec)"},
+		{ID: 3, Language: "de", Region: "AT", Title: "Krieg der Sterne"},
+	}
+
+	tests := []struct {
+		name string
+		tags []string
+		want int64
+	}{
+		{"Language and region", []string{"fr-CA"}, 2},
+		{"Language without a region", []string{"fr"}, 1},
+		{"Region without a title falls back to the language", []string{"fr-BE"}, 1},
+		{"Language in another region", []string{"de-DE"}, 3},
+		{"Case insensitive", []string{"FR-ca"}, 2},
+		{"Later preference", []string{"ja", "de"}, 3},
+		{"No match", []string{"ja"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int64
+			if title := LocalisedTitle(titles, tt.tags); title != nil {
+				got = title.ID
+			}
+
+			if got != tt.want {
+				t.Errorf("LocalisedTitle(%q) = title %d; want title %d", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSearchAlternateTitles checks that movies can be found by their alternate titles, when the
+// movie's text search configuration stems a title differently from the word that was typed.
+func TestSearchAlternateTitles(t *testing.T) {
+	models := NewModels(newTestDB(t))
+
+	movie := &Movie{
+		Title:         "Untouchable",
+		Year:          2011,
+		Runtime:       112,
+		Genres:        []string{"drama"},
+		Languages:     []string{"en"},
+		ReleaseStatus: ReleaseReleased,
+	}
+
+	err := models.Movies.Insert(movie, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := models.Movies.Delete(movie.ID, movie.Version, 0); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// The English configuration stems "intouchables" to "intouch".
+	err = models.MovieTitles.Insert(&MovieTitle{MovieID: movie.ID, Language: "fr", Title: "Intouchables"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafeList: []string{"id"}}
+
+	for _, search := range []string{"Intouchables", "intouchables untouchable"} {
+		mf := NewMovieFilters()
+		mf.Search = ParseSearchQuery(search)
+		mf.IDs = []int64{movie.ID}
+
+		movies, _, err := models.Movies.GetAll(mf, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(movies) != 1 {
+			t.Errorf("search %q found %d movies; want 1", search, len(movies))
+		}
+	}
+}
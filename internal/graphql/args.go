@@ -0,0 +1,123 @@
+package graphql
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Int returns the named argument as an int, or def if it wasn't provided or is null. Integer
+// literals in a query are parsed as int64, and numbers in the variables are usually float64, so
+// both are accepted as long as they are whole numbers.
+func (p ResolveParams) Int(name string, def int) (int, error) {
+	value, ok := p.Args[name]
+	if !ok || value == nil {
+		return def, nil
+	}
+
+	i, ok := toInt(value)
+	if !ok {
+		return 0, argumentError(name, "an Int")
+	}
+
+	return i, nil
+}
+
+// String returns the named argument as a string, or def if it wasn't provided or is null.
+func (p ResolveParams) String(name, def string) (string, error) {
+	value, ok := p.Args[name]
+	if !ok || value == nil {
+		return def, nil
+	}
+
+	switch s := value.(type) {
+	case string:
+		return s, nil
+	case Enum:
+		return string(s), nil
+	}
+
+	return "", argumentError(name, "a String")
+}
+
+// Bool returns the named argument as a bool, or def if it wasn't provided or is null.
+func (p ResolveParams) Bool(name string, def bool) (bool, error) {
+	value, ok := p.Args[name]
+	if !ok || value == nil {
+		return def, nil
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		return false, argumentError(name, "a Boolean")
+	}
+
+	return b, nil
+}
+
+// Strings returns the named argument as a slice of strings, or nil if it wasn't provided or is
+// null. A single string is treated as a list of one, as GraphQL input coercion requires.
+func (p ResolveParams) Strings(name string) ([]string, error) {
+	value, ok := p.Args[name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, argumentError(name, "a list of Strings")
+	}
+
+	strings := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, argumentError(name, "a list of Strings")
+		}
+		strings[i] = s
+	}
+
+	return strings, nil
+}
+
+// Object returns the named argument, which must be an input object, as JSON, so that it can be
+// decoded into a struct. It returns nil if the argument wasn't provided or is null.
+func (p ResolveParams) Object(name string) (json.RawMessage, error) {
+	value, ok := p.Args[name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, argumentError(name, "an object")
+	}
+
+	return json.Marshal(value)
+}
+
+func argumentError(name, want string) *Error {
+	return Errorf("BAD_USER_INPUT", "argument %q must be %s", name, want)
+}
+
+// toInt converts an integer argument value to an int.
+func toInt(value interface{}) (int, bool) {
+	switch n := value.(type) {
+	case int64:
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return 0, false
+		}
+		return int(n), true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt32 || n > math.MaxInt32 {
+			return 0, false
+		}
+		return int(n), true
+	case int:
+		return n, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,681 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema describes the fields which can be queried, starting from the root Query and Mutation
+// types. Mutation is nil if the schema has no mutations.
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+}
+
+// Object is an object type, such as Movie, and its fields.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type. Fields whose value is an object (or a list of objects) set
+// Type, and must have a selection of subfields in a query. Other fields are scalars (or lists of
+// scalars), which are written out as JSON.
+type Field struct {
+	Type *Object
+	// Args lists the names of the arguments which the field accepts.
+	Args []string
+	// Cost is the cost of resolving the field, which defaults to 1. The cost of a list field's
+	// subfields is multiplied by the size of the list, which is the value of the SizeArg
+	// argument if the query provides it, or ListSize otherwise.
+	Cost     int
+	ListSize int
+	SizeArg  string
+	// Resolve returns the value of the field. Any slice returned is treated as a list.
+	Resolve func(p ResolveParams) (interface{}, error)
+}
+
+// ResolveParams holds the parameters passed to a field's Resolve function. Source is the value
+// of the parent object, which is nil for the root fields, and Args holds the field's arguments
+// with any variables substituted. Node is the field in the query, which a resolver can use to
+// look ahead at the subfields being selected and batch load them.
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+	Node    *Node
+}
+
+// Node is a field selected by a query, after fragments and directives have been applied and the
+// fields with the same response key have been merged.
+type Node struct {
+	Alias    string // The response key, which is the field name unless it was aliased.
+	Name     string
+	Args     map[string]interface{}
+	Children []*Node
+}
+
+// Selects reports whether a path of subfields is selected below the node, such as
+// Selects("movies", "credits").
+func (n *Node) Selects(path ...string) bool {
+	if len(path) == 0 {
+		return true
+	}
+
+	for _, child := range n.Children {
+		if child.Name == path[0] && child.Selects(path[1:]...) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Error is a GraphQL error. Errors returned by a resolver are reported with the path of the field
+// which failed. Resolvers can return an *Error to set the message and extensions which the client
+// sees; other errors are reported as an internal error.
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an *Error with a formatted message and the given extension code, such as
+// "FORBIDDEN".
+func Errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Extensions: map[string]interface{}{"code": code}}
+}
+
+// Params holds a request to execute. MaxDepth and MaxCost limit the depth of nested fields and
+// the total cost of the fields in the operation, and are ignored if zero. LogError is called with
+// the internal errors returned by resolvers.
+type Params struct {
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	MaxDepth      int
+	MaxCost       int
+	LogError      func(error)
+}
+
+// Response is the result of executing an operation. Data holds the value of each root field, in
+// the order that they were selected, and is partial if there are errors.
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// internalErrorMessage is the message reported for errors which aren't an *Error.
+const internalErrorMessage = "the server encountered a problem and could not process your request"
+
+// Execute parses, validates and executes an operation against the schema. It returns an *Error
+// (or a *SyntaxError) without executing anything if the query is invalid or exceeds the depth
+// and cost limits. Errors from resolvers are instead returned in the response, alongside the
+// fields which were resolved.
+func Execute(ctx context.Context, schema *Schema, params Params) (*Response, error) {
+	doc, err := Parse(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := doc.operation(params.OperationName)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *Object
+
+	switch op.Type {
+	case "query":
+		root = schema.Query
+	case "mutation":
+		root = schema.Mutation
+	}
+
+	if root == nil {
+		return nil, &Error{Message: fmt.Sprintf("%ss are not supported", op.Type)}
+	}
+
+	err = doc.checkFragmentCycles()
+	if err != nil {
+		return nil, err
+	}
+
+	variables, err := op.coerceVariables(params.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validation{doc: doc, variables: variables, maxDepth: params.MaxDepth}
+
+	nodes, cost, err := v.collect(root, op.Selections, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.MaxCost > 0 && cost > params.MaxCost {
+		return nil, &Error{Message: fmt.Sprintf("the query has a cost of %d, which is more than the maximum of %d", cost, params.MaxCost)}
+	}
+
+	e := &executor{ctx: ctx, logError: params.LogError}
+
+	data := e.executeFields(root, nil, nodes, nil)
+
+	return &Response{Data: data, Errors: e.errors}, nil
+}
+
+// operation returns the operation to execute, which is the named one, or the only one in the
+// document if no name is given.
+func (doc *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, &Error{Message: "the operation name must be provided when the document contains more than one operation"}
+		}
+		return doc.Operations[0], nil
+	}
+
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+
+	return nil, &Error{Message: fmt.Sprintf("unknown operation %q", name)}
+}
+
+// checkFragmentCycles returns an error if a fragment spreads itself, directly or through other
+// fragments, as it would otherwise be expanded forever.
+func (doc *Document) checkFragmentCycles() error {
+	names := make([]string, 0, len(doc.Fragments))
+	for name := range doc.Fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// done holds the fragments which have been checked, and visiting those being checked.
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(name string) error
+
+	visit = func(name string) error {
+		fragment, ok := doc.Fragments[name]
+		if !ok || done[name] {
+			return nil
+		}
+
+		if visiting[name] {
+			return &Error{Message: fmt.Sprintf("fragment %q must not spread itself", name)}
+		}
+
+		visiting[name] = true
+		for _, spread := range spreads(fragment.Selections) {
+			if err := visit(spread); err != nil {
+				return err
+			}
+		}
+		delete(visiting, name)
+		done[name] = true
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// spreads returns the names of the fragments spread anywhere within a selection set.
+func spreads(selections []Selection) []string {
+	var names []string
+
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldSelection:
+			names = append(names, spreads(s.Selections)...)
+		case *InlineFragment:
+			names = append(names, spreads(s.Selections)...)
+		case *FragmentSpread:
+			names = append(names, s.Name)
+		}
+	}
+
+	return names
+}
+
+// coerceVariables returns the values of the operation's variables, applying their defaults and
+// checking that required variables have been provided. The values themselves are checked when
+// a resolver reads them as arguments.
+func (op *Operation) coerceVariables(provided map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{})
+
+	for _, def := range op.Variables {
+		if _, exists := variables[def.Name]; exists {
+			return nil, &Error{Message: fmt.Sprintf("there can only be one variable named $%s", def.Name)}
+		}
+
+		value, ok := provided[def.Name]
+		if !ok {
+			value = def.Default
+		}
+
+		if value == nil && strings.HasSuffix(def.Type, "!") {
+			return nil, &Error{Message: fmt.Sprintf("variable $%s of required type %s was not provided", def.Name, def.Type)}
+		}
+
+		variables[def.Name] = value
+	}
+
+	return variables, nil
+}
+
+// validation collects the fields selected by an operation, checking them against the schema.
+type validation struct {
+	doc       *Document
+	variables map[string]interface{}
+	maxDepth  int
+}
+
+// collect returns the nodes for a selection set on an object type at the given depth, and their
+// total cost.
+func (v *validation) collect(obj *Object, selections []Selection, depth int) ([]*Node, int, error) {
+	if v.maxDepth > 0 && depth > v.maxDepth {
+		return nil, 0, &Error{Message: fmt.Sprintf("the query must not be nested more than %d levels deep", v.maxDepth)}
+	}
+
+	// Group the fields by their response key, keeping the order in which the keys first appear.
+	var keys []string
+	fields := make(map[string][]*FieldSelection)
+
+	err := v.flatten(obj, selections, make(map[string]bool), func(field *FieldSelection) {
+		key := field.Alias
+		if key == "" {
+			key = field.Name
+		}
+
+		if _, exists := fields[key]; !exists {
+			keys = append(keys, key)
+		}
+		fields[key] = append(fields[key], field)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var nodes []*Node
+	var total int
+
+	for _, key := range keys {
+		node, cost, err := v.node(obj, key, fields[key], depth)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		nodes = append(nodes, node)
+		total = addCost(total, cost)
+	}
+
+	return nodes, total, nil
+}
+
+// flatten calls fn for each field in a selection set which isn't skipped by a directive,
+// including the fields in its fragments. Each named fragment is only expanded once, as spreading
+// it again would only select the same fields, and spread records the fragments expanded so far.
+func (v *validation) flatten(obj *Object, selections []Selection, spread map[string]bool, fn func(*FieldSelection)) error {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldSelection:
+			include, err := v.include(s.Directives)
+			if err != nil {
+				return err
+			}
+			if include {
+				fn(s)
+			}
+
+		case *InlineFragment:
+			include, err := v.include(s.Directives)
+			if err != nil {
+				return err
+			}
+			if !include {
+				continue
+			}
+
+			if s.TypeName != "" && s.TypeName != obj.Name {
+				return &Error{Message: fmt.Sprintf("a fragment on %s can't be spread within %s", s.TypeName, obj.Name)}
+			}
+
+			err = v.flatten(obj, s.Selections, spread, fn)
+			if err != nil {
+				return err
+			}
+
+		case *FragmentSpread:
+			include, err := v.include(s.Directives)
+			if err != nil {
+				return err
+			}
+			if !include || spread[s.Name] {
+				continue
+			}
+
+			fragment, ok := v.doc.Fragments[s.Name]
+			if !ok {
+				return &Error{Message: fmt.Sprintf("unknown fragment %q", s.Name)}
+			}
+
+			if fragment.TypeName != obj.Name {
+				return &Error{Message: fmt.Sprintf("fragment %q on %s can't be spread within %s", s.Name, fragment.TypeName, obj.Name)}
+			}
+
+			spread[s.Name] = true
+
+			err = v.flatten(obj, fragment.Selections, spread, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// include evaluates the @skip and @include directives, and reports whether the selection they
+// are on should be included.
+func (v *validation) include(directives []*Directive) (bool, error) {
+	for _, directive := range directives {
+		if directive.Name != "skip" && directive.Name != "include" {
+			return false, &Error{Message: fmt.Sprintf("unknown directive @%s", directive.Name)}
+		}
+
+		value, err := v.resolve(directive.Arguments["if"])
+		if err != nil {
+			return false, err
+		}
+
+		condition, ok := value.(bool)
+		if !ok {
+			return false, &Error{Message: fmt.Sprintf("the \"if\" argument of @%s must be a Boolean", directive.Name)}
+		}
+
+		if condition == (directive.Name == "skip") {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// node merges the fields selected under the same response key into a node, and returns the node
+// and its cost.
+func (v *validation) node(obj *Object, key string, fields []*FieldSelection, depth int) (*Node, int, error) {
+	first := fields[0]
+
+	args, err := v.resolve(first.Arguments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	node := &Node{Alias: key, Name: first.Name, Args: args.(map[string]interface{})}
+
+	var selections []Selection
+
+	for _, field := range fields {
+		if field.Name != first.Name || !reflect.DeepEqual(field.Arguments, first.Arguments) {
+			return nil, 0, &Error{Message: fmt.Sprintf("fields selected as %q conflict, because they are different fields or have different arguments", key)}
+		}
+		selections = append(selections, field.Selections...)
+	}
+
+	if node.Name == "__typename" {
+		if len(selections) > 0 {
+			return nil, 0, &Error{Message: "field \"__typename\" must not have a selection of subfields"}
+		}
+		return node, 0, nil
+	}
+
+	def, ok := obj.Fields[node.Name]
+	if !ok {
+		return nil, 0, &Error{Message: fmt.Sprintf("cannot query field %q on type %s", node.Name, obj.Name)}
+	}
+
+	for name := range node.Args {
+		if !contains(def.Args, name) {
+			return nil, 0, &Error{Message: fmt.Sprintf("unknown argument %q on field %s.%s", name, obj.Name, node.Name)}
+		}
+	}
+
+	cost := def.Cost
+	if cost == 0 {
+		cost = 1
+	}
+
+	if def.Type == nil {
+		if len(selections) > 0 {
+			return nil, 0, &Error{Message: fmt.Sprintf("field %q must not have a selection of subfields", node.Name)}
+		}
+		return node, cost, nil
+	}
+
+	if len(selections) == 0 {
+		return nil, 0, &Error{Message: fmt.Sprintf("field %q of type %s must have a selection of subfields", node.Name, def.Type.Name)}
+	}
+
+	var childCost int
+
+	node.Children, childCost, err = v.collect(def.Type, selections, depth+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := 1
+	if def.ListSize > 0 {
+		size = def.ListSize
+	}
+	if n, ok := toInt(node.Args[def.SizeArg]); ok && def.SizeArg != "" && n > 0 {
+		size = n
+	}
+
+	return node, addCost(cost, multiplyCost(size, childCost)), nil
+}
+
+// resolve returns an argument value with its variables substituted.
+func (v *validation) resolve(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case Variable:
+		resolved, ok := v.variables[string(value)]
+		if !ok {
+			return nil, &Error{Message: fmt.Sprintf("variable $%s is not defined", value)}
+		}
+		return resolved, nil
+
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			resolved, err := v.resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			resolved, err := v.resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = resolved
+		}
+		return object, nil
+	}
+
+	return value, nil
+}
+
+// executor executes the fields of an operation, one at a time, collecting the errors from their
+// resolvers.
+type executor struct {
+	ctx      context.Context
+	logError func(error)
+	errors   []*Error
+}
+
+func (e *executor) executeFields(obj *Object, source interface{}, nodes []*Node, path []interface{}) *orderedMap {
+	result := &orderedMap{}
+
+	for _, node := range nodes {
+		if node.Name == "__typename" {
+			result.set(node.Alias, obj.Name)
+			continue
+		}
+
+		def := obj.Fields[node.Name]
+		fieldPath := append(path[:len(path):len(path)], node.Alias)
+
+		value, err := def.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: node.Args, Node: node})
+		if err != nil {
+			e.addError(err, fieldPath)
+			result.set(node.Alias, nil)
+			continue
+		}
+
+		result.set(node.Alias, e.complete(def.Type, value, node, fieldPath))
+	}
+
+	return result
+}
+
+// complete returns the result of a field from the value returned by its resolver, executing the
+// subfields of each object in the value.
+func (e *executor) complete(obj *Object, value interface{}, node *Node, path []interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Map, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+
+		if obj == nil {
+			return value
+		}
+
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = e.complete(obj, rv.Index(i).Interface(), node, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+
+	if obj == nil {
+		return value
+	}
+
+	return e.executeFields(obj, value, node.Children, path)
+}
+
+func (e *executor) addError(err error, path []interface{}) {
+	var gqlErr *Error
+
+	if !errors.As(err, &gqlErr) {
+		if e.logError != nil {
+			e.logError(err)
+		}
+		gqlErr = &Error{Message: internalErrorMessage}
+	}
+
+	e.errors = append(e.errors, &Error{Message: gqlErr.Message, Path: path, Extensions: gqlErr.Extensions})
+}
+
+// orderedMap is a JSON object which keeps its keys in the order they were set, as GraphQL
+// responses must list fields in the order they were selected.
+type orderedMap struct {
+	keys   []string
+	values []interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(m.values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// addCost and multiplyCost add and multiply costs, saturating rather than overflowing for
+// absurdly large queries.
+func addCost(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+	return a + b
+}
+
+func multiplyCost(a, b int) int {
+	if b != 0 && a > math.MaxInt32/b {
+		return math.MaxInt32
+	}
+	return a * b
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testBook struct {
+	ID    int64
+	Title string
+	Tags  []string
+}
+
+// testSchema returns a schema with a books query, and the number of times it was resolved.
+func testSchema(loads *int) *Schema {
+	book := &Object{Name: "Book", Fields: map[string]*Field{
+		"id":    {Resolve: func(p ResolveParams) (interface{}, error) { return p.Source.(*testBook).ID, nil }},
+		"title": {Resolve: func(p ResolveParams) (interface{}, error) { return p.Source.(*testBook).Title, nil }},
+		"tags":  {Resolve: func(p ResolveParams) (interface{}, error) { return p.Source.(*testBook).Tags, nil }},
+		"secret": {Resolve: func(p ResolveParams) (interface{}, error) {
+			return nil, Errorf("FORBIDDEN", "not allowed")
+		}},
+		"broken": {Resolve: func(p ResolveParams) (interface{}, error) {
+			return nil, errors.New("connection refused")
+		}},
+	}}
+	book.Fields["related"] = &Field{Type: book, ListSize: 10, Resolve: func(p ResolveParams) (interface{}, error) {
+		return []*testBook{}, nil
+	}}
+
+	query := &Object{Name: "Query", Fields: map[string]*Field{
+		"books": {Type: book, Args: []string{"first"}, ListSize: 10, SizeArg: "first", Resolve: func(p ResolveParams) (interface{}, error) {
+			*loads++
+
+			first, err := p.Int("first", 2)
+			if err != nil {
+				return nil, err
+			}
+
+			books := []*testBook{{1, "Dune", []string{"sci-fi"}}, {2, "Emma", nil}}
+			if first < len(books) {
+				books = books[:first]
+			}
+			return books, nil
+		}},
+	}}
+
+	return &Schema{Query: query}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{"Fields in selection order", `{ books { title id } }`, nil,
+			`{"data":{"books":[{"title":"Dune","id":1},{"title":"Emma","id":2}]}}`},
+		{"Aliases and arguments", `{ first: books(first: 1) { name: title } }`, nil,
+			`{"data":{"first":[{"name":"Dune"}]}}`},
+		{"Variables from JSON", `query ($n: Int = 2) { books(first: $n) { id } }`, map[string]interface{}{"n": 1.0},
+			`{"data":{"books":[{"id":1}]}}`},
+		{"Fragments and merged fields", `{ books(first: 1) { ...Parts ... on Book { tags } id } } fragment Parts on Book { id title }`, nil,
+			`{"data":{"books":[{"id":1,"title":"Dune","tags":["sci-fi"]}]}}`},
+		{"Directives", `query ($skip: Boolean!) { books(first: 1) { id @skip(if: $skip) title @include(if: false) __typename } }`, map[string]interface{}{"skip": true},
+			`{"data":{"books":[{"__typename":"Book"}]}}`},
+		{"Field errors", `{ books(first: 1) { id secret broken } }`, nil,
+			`{"data":{"books":[{"id":1,"secret":null,"broken":null}]},"errors":[` +
+				`{"message":"not allowed","path":["books",0,"secret"],"extensions":{"code":"FORBIDDEN"}},` +
+				`{"message":"the server encountered a problem and could not process your request","path":["books",0,"broken"]}]}`},
+		{"Invalid argument", `{ books(first: "one") { id } }`, nil,
+			`{"data":{"books":null},"errors":[{"message":"argument \"first\" must be an Int","path":["books"],"extensions":{"code":"BAD_USER_INPUT"}}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loads int
+			var logged []error
+
+			resp, err := Execute(context.Background(), testSchema(&loads), Params{
+				Query:     tt.query,
+				Variables: tt.variables,
+				LogError:  func(err error) { logged = append(logged, err) },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			js, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(js) != tt.want {
+				t.Errorf("got %s; want %s", js, tt.want)
+			}
+
+			if loads != 1 {
+				t.Errorf("books was resolved %d times; want 1", loads)
+			}
+
+			if strings.Contains(tt.query, "broken") && len(logged) != 1 {
+				t.Errorf("got %d logged errors; want 1", len(logged))
+			}
+		})
+	}
+}
+
+func TestExecuteRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"Syntax error", `{ books { id }`, "syntax error at line 1, column 15: expected a name, found end of document"},
+		{"Unknown field", `{ books { isbn } }`, `cannot query field "isbn" on type Book`},
+		{"Unknown argument", `{ books(last: 1) { id } }`, `unknown argument "last" on field Query.books`},
+		{"Missing subfields", `{ books }`, `field "books" of type Book must have a selection of subfields`},
+		{"Conflicting fields", `{ books { id: title id } }`, `fields selected as "id" conflict, because they are different fields or have different arguments`},
+		{"Required variable", `query ($n: Int!) { books(first: $n) { id } }`, "variable $n of required type Int! was not provided"},
+		{"Undefined variable", `{ books(first: $n) { id } }`, "variable $n is not defined"},
+		{"Fragment cycle", `{ books { ...A } } fragment A on Book { related { ...A } }`, `fragment "A" must not spread itself`},
+		{"Too deep", `{ books { related { related { related { id } } } } }`, "the query must not be nested more than 4 levels deep"},
+		{"Too costly", `{ books(first: 100) { related { id title } } }`, "the query has a cost of 2101, which is more than the maximum of 1000"},
+		{"Mutation", `mutation { books { id } }`, "mutations are not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loads int
+
+			_, err := Execute(context.Background(), testSchema(&loads), Params{Query: tt.query, MaxDepth: 4, MaxCost: 1000})
+			if err == nil {
+				t.Fatal("query was executed")
+			}
+
+			if err.Error() != tt.want {
+				t.Errorf("got error %q; want %q", err, tt.want)
+			}
+
+			if loads != 0 {
+				t.Error("books was resolved")
+			}
+		})
+	}
+}
@@ -0,0 +1,664 @@
+// Package graphql parses and executes GraphQL queries and mutations against a schema of Go
+// resolver functions. It supports the parts of the GraphQL language which clients use to fetch
+// data: operations with variables, aliases, arguments, fragments and the @skip and @include
+// directives. Introspection and subscriptions aren't supported.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed GraphQL document, holding its operations and the fragments they use.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query or mutation in a document.
+type Operation struct {
+	Type       string // "query" or "mutation".
+	Name       string
+	Variables  []*VariableDefinition
+	Selections []Selection
+}
+
+// VariableDefinition declares a variable of an operation. Type is the variable's type as
+// written, such as "[String!]!".
+type VariableDefinition struct {
+	Name    string
+	Type    string
+	Default interface{}
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name       string
+	TypeName   string
+	Selections []Selection
+}
+
+// Selection is one of *FieldSelection, *FragmentSpread or *InlineFragment.
+type Selection interface{}
+
+// FieldSelection selects a field, optionally under an alias and with arguments.
+type FieldSelection struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]interface{}
+	Directives []*Directive
+	Selections []Selection
+}
+
+// FragmentSpread includes the selections of a named fragment.
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment includes a set of selections in place.
+type InlineFragment struct {
+	TypeName   string
+	Directives []*Directive
+	Selections []Selection
+}
+
+// Directive is a directive such as @skip(if: $flag).
+type Directive struct {
+	Name      string
+	Arguments map[string]interface{}
+}
+
+// Variable is a reference to a variable in an argument value.
+type Variable string
+
+// Enum is an enum value in an argument, such as TITLE in sort: TITLE.
+type Enum string
+
+// SyntaxError is returned by Parse when a document isn't valid GraphQL.
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// The kinds of lexical token.
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// parser is a recursive descent parser over the tokens of a document.
+type parser struct {
+	src    string
+	pos    int
+	tok    token
+	tokens int
+}
+
+// maxTokens limits the size of a document, so that huge documents are rejected before they are
+// executed.
+const maxTokens = 10000
+
+// Parse parses a GraphQL document. Documents containing type system definitions are rejected,
+// as are documents with duplicate operation or fragment names.
+func Parse(src string) (doc *Document, err error) {
+	p := &parser{src: src}
+
+	// The parsing functions panic with a *SyntaxError, to avoid checking for errors at every
+	// step of the recursion.
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = syntaxErr
+		}
+	}()
+
+	p.next()
+
+	doc = &Document{Fragments: make(map[string]*Fragment)}
+	names := make(map[string]bool)
+
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: p.parseSelectionSet()})
+		case p.peek(tokenName, "fragment"):
+			fragment := p.parseFragment()
+			if _, exists := doc.Fragments[fragment.Name]; exists {
+				p.fail("there can only be one fragment named %q", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		case p.tok.kind == tokenName:
+			op := p.parseOperation()
+			if op.Name != "" && names[op.Name] {
+				p.fail("there can only be one operation named %q", op.Name)
+			}
+			names[op.Name] = true
+			doc.Operations = append(doc.Operations, op)
+		default:
+			p.fail("unexpected %s", p.describe())
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		p.fail("the document doesn't contain an operation")
+	}
+
+	if len(doc.Operations) > 1 {
+		for _, op := range doc.Operations {
+			if op.Name == "" {
+				p.fail("an anonymous operation must be the only operation in the document")
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func (p *parser) parseOperation() *Operation {
+	op := &Operation{Type: p.tok.value}
+
+	switch op.Type {
+	case "query", "mutation", "subscription":
+		p.next()
+	default:
+		p.fail("unexpected %s", p.describe())
+	}
+
+	if p.tok.kind == tokenName {
+		op.Name = p.parseName()
+	}
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect(tokenPunctuator, "$")
+			def := &VariableDefinition{Name: p.parseName()}
+			p.expect(tokenPunctuator, ":")
+			def.Type = p.parseType()
+			if p.skip("=") {
+				def.Default = p.parseValue(true)
+			}
+			op.Variables = append(op.Variables, def)
+		}
+	}
+
+	p.parseDirectives()
+	op.Selections = p.parseSelectionSet()
+
+	return op
+}
+
+func (p *parser) parseFragment() *Fragment {
+	p.next()
+
+	fragment := &Fragment{Name: p.parseName()}
+	if fragment.Name == "on" {
+		p.fail("a fragment can't be named \"on\"")
+	}
+
+	p.expect(tokenName, "on")
+	fragment.TypeName = p.parseName()
+	p.parseDirectives()
+	fragment.Selections = p.parseSelectionSet()
+
+	return fragment
+}
+
+func (p *parser) parseType() string {
+	var t string
+
+	if p.skip("[") {
+		t = "[" + p.parseType() + "]"
+		p.expect(tokenPunctuator, "]")
+	} else {
+		t = p.parseName()
+	}
+
+	if p.skip("!") {
+		t += "!"
+	}
+
+	return t
+}
+
+func (p *parser) parseSelectionSet() []Selection {
+	p.expect(tokenPunctuator, "{")
+
+	var selections []Selection
+
+	for !p.skip("}") {
+		if p.skip("...") {
+			if p.tok.kind == tokenName && p.tok.value != "on" {
+				selections = append(selections, &FragmentSpread{Name: p.parseName(), Directives: p.parseDirectives()})
+				continue
+			}
+
+			fragment := &InlineFragment{}
+			if p.tok.kind == tokenName {
+				p.next()
+				fragment.TypeName = p.parseName()
+			}
+			fragment.Directives = p.parseDirectives()
+			fragment.Selections = p.parseSelectionSet()
+
+			selections = append(selections, fragment)
+			continue
+		}
+
+		field := &FieldSelection{Name: p.parseName()}
+		if p.skip(":") {
+			field.Alias = field.Name
+			field.Name = p.parseName()
+		}
+
+		field.Arguments = p.parseArguments()
+		field.Directives = p.parseDirectives()
+
+		if p.peek(tokenPunctuator, "{") {
+			field.Selections = p.parseSelectionSet()
+		}
+
+		selections = append(selections, field)
+	}
+
+	if len(selections) == 0 {
+		p.fail("a selection set must not be empty")
+	}
+
+	return selections
+}
+
+func (p *parser) parseArguments() map[string]interface{} {
+	args := make(map[string]interface{})
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			name := p.parseName()
+			if _, exists := args[name]; exists {
+				p.fail("there can only be one argument named %q", name)
+			}
+			p.expect(tokenPunctuator, ":")
+			args[name] = p.parseValue(false)
+		}
+	}
+
+	return args
+}
+
+func (p *parser) parseDirectives() []*Directive {
+	var directives []*Directive
+
+	for p.skip("@") {
+		directives = append(directives, &Directive{Name: p.parseName(), Arguments: p.parseArguments()})
+	}
+
+	return directives
+}
+
+// parseValue parses an argument value. Variables aren't allowed in constant values, such as the
+// default value of a variable.
+func (p *parser) parseValue(constant bool) interface{} {
+	tok := p.tok
+
+	switch tok.kind {
+	case tokenInt:
+		p.next()
+		i, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			p.fail("%s is out of range", tok.value)
+		}
+		return i
+	case tokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			p.fail("%s is out of range", tok.value)
+		}
+		return f
+	case tokenString:
+		p.next()
+		return tok.value
+	case tokenName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		default:
+			return Enum(tok.value)
+		}
+	}
+
+	switch {
+	case p.skip("$"):
+		if constant {
+			p.fail("unexpected variable in a constant value")
+		}
+		return Variable(p.parseName())
+	case p.skip("["):
+		list := []interface{}{}
+		for !p.skip("]") {
+			list = append(list, p.parseValue(constant))
+		}
+		return list
+	case p.skip("{"):
+		object := make(map[string]interface{})
+		for !p.skip("}") {
+			name := p.parseName()
+			p.expect(tokenPunctuator, ":")
+			object[name] = p.parseValue(constant)
+		}
+		return object
+	}
+
+	p.fail("unexpected %s", p.describe())
+	return nil
+}
+
+func (p *parser) parseName() string {
+	if p.tok.kind != tokenName {
+		p.fail("expected a name, found %s", p.describe())
+	}
+
+	name := p.tok.value
+	p.next()
+
+	return name
+}
+
+// peek reports whether the current token is of the given kind and value.
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// skip consumes the current token if it is the given punctuator, and reports whether it was.
+func (p *parser) skip(punctuator string) bool {
+	if !p.peek(tokenPunctuator, punctuator) {
+		return false
+	}
+
+	p.next()
+	return true
+}
+
+// expect consumes the current token, failing if it isn't of the given kind and value.
+func (p *parser) expect(kind int, value string) {
+	if !p.peek(kind, value) {
+		p.fail("expected %q, found %s", value, p.describe())
+	}
+
+	p.next()
+}
+
+// describe returns a description of the current token for error messages.
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokenEOF:
+		return "end of document"
+	case tokenString:
+		return "string " + strconv.Quote(p.tok.value)
+	default:
+		return strconv.Quote(p.tok.value)
+	}
+}
+
+// fail panics with a *SyntaxError at the current token.
+func (p *parser) fail(format string, args ...interface{}) {
+	p.failAt(p.tok.pos, format, args...)
+}
+
+func (p *parser) failAt(pos int, format string, args ...interface{}) {
+	before := p.src[:pos]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+
+	panic(&SyntaxError{Message: fmt.Sprintf(format, args...), Line: line, Column: column})
+}
+
+// next reads the next token into p.tok, skipping whitespace, commas and comments.
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+			continue
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		case strings.HasPrefix(p.src[p.pos:], "\uFEFF"):
+			p.pos += len("\uFEFF")
+			continue
+		}
+
+		break
+	}
+
+	p.tokens++
+	if p.tokens > maxTokens {
+		p.failAt(p.pos, "the document must not contain more than %d tokens", maxTokens)
+	}
+
+	start := p.pos
+
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokenPunctuator, value: "...", pos: start}
+	case strings.ContainsRune("!$&():=@[]{}|", rune(c)):
+		p.pos++
+		p.tok = token{kind: tokenPunctuator, value: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, value: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.tok = p.readNumber()
+	case c == '"':
+		p.tok = p.readString()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.failAt(start, "unexpected character %q", r)
+	}
+}
+
+func (p *parser) readNumber() token {
+	start := p.pos
+	kind := tokenInt
+
+	digits := func() {
+		from := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == from {
+			p.failAt(p.pos, "invalid number")
+		}
+	}
+
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+
+	from := p.pos
+	digits()
+	if p.pos-from > 1 && p.src[from] == '0' {
+		p.failAt(from, "invalid number, unexpected leading zero")
+	}
+
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokenFloat
+		p.pos++
+		digits()
+	}
+
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+
+	if p.pos < len(p.src) && (p.src[p.pos] == '_' || p.src[p.pos] == '.' || isLetter(p.src[p.pos])) {
+		p.failAt(p.pos, "invalid number")
+	}
+
+	return token{kind: kind, value: p.src[start:p.pos], pos: start}
+}
+
+func (p *parser) readString() token {
+	start := p.pos
+
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		// Find the closing quotes, skipping any escaped as \""".
+		end := p.pos + 3
+		for {
+			i := strings.Index(p.src[end:], `"""`)
+			if i < 0 {
+				p.failAt(start, "unterminated string")
+			}
+			end += i
+			if p.src[end-1] != '\\' {
+				break
+			}
+			end += 3
+		}
+
+		raw := p.src[p.pos+3 : end]
+		p.pos = end + 3
+
+		return token{kind: tokenString, value: blockString(raw), pos: start}
+	}
+
+	var b strings.Builder
+
+	p.pos++
+
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.failAt(start, "unterminated string")
+		}
+
+		c := p.src[p.pos]
+
+		switch c {
+		case '"':
+			p.pos++
+			return token{kind: tokenString, value: b.String(), pos: start}
+		case '\\':
+			if p.pos+1 >= len(p.src) {
+				p.failAt(start, "unterminated string")
+			}
+
+			escape := p.src[p.pos+1]
+			p.pos += 2
+
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					p.failAt(p.pos-2, "invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.failAt(p.pos-2, "invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				p.failAt(p.pos-2, "invalid escape sequence \\%c", escape)
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// blockString returns the value of a block string, removing the common indentation of its lines
+// and any leading and trailing blank lines.
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, `\"""`, `"""`), "\n")
+
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,186 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	// Register the GIF decoder with the image package. Animated GIFs are reduced to their
+	// first frame.
+	_ "image/gif"
+)
+
+// ErrUnsupportedType is returned when the sniffed content type of an upload isn't one of the
+// supported image types.
+var ErrUnsupportedType = errors.New("unsupported image type")
+
+// ErrTooManyPixels is returned when an image's dimensions are larger than MaxPixels.
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// MaxPixels is the largest number of pixels (width × height) in an image that will be decoded.
+// A small compressed upload can declare huge dimensions, and decoding it would allocate memory
+// for every pixel, so the dimensions are checked before the image is decoded.
+const MaxPixels = 40_000_000
+
+// SupportedTypes holds the image content types that can be decoded.
+var SupportedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// Variant describes a resized version of an image. A Width of 0 means the original size.
+type Variant struct {
+	Name  string
+	Width int
+}
+
+// Image holds an encoded image along with its content type and dimensions.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Sniff returns the content type of the data, based on its leading bytes rather than anything
+// the client told us, and checks that it is one of the SupportedTypes.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+
+	for _, supported := range SupportedTypes {
+		if contentType == supported {
+			return contentType, nil
+		}
+	}
+
+	return contentType, ErrUnsupportedType
+}
+
+// Process decodes the image data and produces one encoded image per variant. Images with more
+// than MaxPixels pixels are rejected with ErrTooManyPixels before they are decoded. Variants are
+// only ever scaled down, preserving the aspect ratio. PNG images stay PNG so that transparency is
+// kept; everything else is encoded as JPEG.
+func Process(data []byte, variants []Variant) (map[string]*Image, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Height > 0 && config.Width > MaxPixels/config.Height {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the source into RGBA once, rather than once for each variant that is resized.
+	if _, ok := src.(*image.RGBA); !ok {
+		rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+		src = rgba
+	}
+
+	outputType := "image/jpeg"
+	if contentType == "image/png" {
+		outputType = "image/png"
+	}
+
+	images := make(map[string]*Image, len(variants))
+
+	for _, variant := range variants {
+		img := src
+		if variant.Width > 0 && variant.Width < src.Bounds().Dx() {
+			img = Resize(src, variant.Width)
+		}
+
+		var buf bytes.Buffer
+
+		switch outputType {
+		case "image/png":
+			err = png.Encode(&buf, img)
+		default:
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		images[variant.Name] = &Image{
+			Data:        buf.Bytes(),
+			ContentType: outputType,
+			Width:       img.Bounds().Dx(),
+			Height:      img.Bounds().Dy(),
+		}
+	}
+
+	return images, nil
+}
+
+// Resize scales src down to the given width, preserving the aspect ratio. Each destination
+// pixel is the average of the source pixels that it covers, which gives good results when
+// shrinking images without needing anything outside the standard library.
+func Resize(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	height := srcH * width / srcW
+	if height < 1 {
+		height = 1
+	}
+
+	// Convert the source into RGBA at the origin, unless it already is, so that reading pixels
+	// below is cheap.
+	rgba, ok := src.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcH / height
+		y1 := (y + 1) * srcH / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := x * srcW / width
+			x1 := (x + 1) * srcW / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := rgba.PixOffset(sx, sy)
+					r += uint64(rgba.Pix[i])
+					g += uint64(rgba.Pix[i+1])
+					b += uint64(rgba.Pix[i+2])
+					a += uint64(rgba.Pix[i+3])
+					n++
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n),
+				G: uint8(g / n),
+				B: uint8(b / n),
+				A: uint8(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,46 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// testPNG returns a 1×1 PNG whose header declares the given dimensions instead.
+func testPNG(t *testing.T, width, height uint32) []byte {
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The IHDR chunk follows the 8 byte signature: its length, type, width and height, and
+	// then the rest of its data and the CRC of its type and data.
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	return data
+}
+
+func TestProcess(t *testing.T) {
+	images, err := Process(testPNG(t, 1, 1), []Variant{{Name: "original"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img := images["original"]; img.Width != 1 || img.Height != 1 || img.ContentType != "image/png" {
+		t.Errorf("got %dx%d %s; want 1x1 image/png", img.Width, img.Height, img.ContentType)
+	}
+
+	_, err = Process(testPNG(t, 50000, 50000), []Variant{{Name: "original"}})
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("got error %v; want %v", err, ErrTooManyPixels)
+	}
+}
@@ -0,0 +1,373 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents to
+// JSON documents.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned when a patch is malformed, such as an operation with an
+	// unknown op or a missing value.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPathNotFound is returned when an operation's path (or from) doesn't exist in the
+	// document, or an array index is out of range.
+	ErrPathNotFound = errors.New("path not found")
+	// ErrTestFailed is returned when a test operation finds a different value at its path.
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the document and returns the patched
+// document. Members of the patch replace those in the document, objects are merged
+// recursively, and a null member removes the member from the document.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+
+	err := json.Unmarshal(doc, &target)
+	if err != nil {
+		return nil, err
+	}
+
+	var p interface{}
+
+	err = json.Unmarshal(patch, &p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+// mergePatch implements the MergePatch algorithm from section 2 of RFC 7396.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], value)
+		}
+	}
+
+	return t
+}
+
+// Operation is a single operation in a JSON Patch document.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies a JSON Patch (RFC 6902) to the document and returns the patched document. The
+// operations are applied in order, and if any of them fails then an error is returned and none
+// of them are applied. The errors returned wrap ErrInvalidPatch, ErrPathNotFound or
+// ErrTestFailed.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+
+	err := json.Unmarshal(doc, &target)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Operation
+
+	err = json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	for i, op := range ops {
+		target, err = apply(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// apply applies a single operation to the document, and returns the new document.
+func apply(doc interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: %s operation must have a value", ErrInvalidPatch, op.Op)
+		}
+
+		err = json.Unmarshal(op.Value, &value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+
+		doc, _, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			// A location can't be moved into one of its own children.
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, fmt.Errorf("%w: cannot move %q into one of its children", ErrInvalidPatch, op.From)
+			}
+
+			doc, value, err = remove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value, err = get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+
+			value = deepCopy(value)
+		}
+
+		return add(doc, path, value)
+	case "test":
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w: %q", ErrTestFailed, op.Path)
+		}
+
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens. The empty
+// pointer refers to the whole document and has no tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: invalid JSON pointer %q", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex parses a reference token as an index into an array of length n. The index n
+// itself (or "-") is only allowed when end is true, for adding to the end of the array.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if end && token == "-" {
+		return n, nil
+	}
+
+	// Indexes are non-negative integers without leading zeros.
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPathNotFound, token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > n || (i == n && !end) {
+		return 0, fmt.Errorf("%w: array index %q out of range", ErrPathNotFound, token)
+	}
+
+	return i, nil
+}
+
+// get returns the value at the path.
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	}
+
+	return doc, nil
+}
+
+// modify finds the container holding the last token of the path and replaces it with the
+// result of fn. Each container on the way down is updated with its modified child, since
+// changing the length of an array creates a new slice.
+func modify(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, path[0])
+		}
+
+		child, err := modify(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+
+		node[path[0]] = child
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := modify(node[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+
+		node[i] = child
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrPathNotFound, path[0])
+	}
+}
+
+// add adds the value at the path, replacing an existing object member or inserting into an
+// array, and returns the new document.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return modify(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	})
+}
+
+// remove removes the value at the path, and returns the new document along with the value that
+// was removed.
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+
+	var removed interface{}
+
+	doc, err := modify(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+			}
+
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return doc, removed, nil
+}
+
+// deepCopy returns a copy of a decoded JSON value which shares no objects or arrays with it.
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for name, member := range v {
+			c[name] = deepCopy(member)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, element := range v {
+			c[i] = deepCopy(element)
+		}
+		return c
+	default:
+		return v
+	}
+}
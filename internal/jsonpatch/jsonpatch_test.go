@@ -0,0 +1,115 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// equalJSON reports whether two JSON documents hold the same value.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	var x, y interface{}
+
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+
+	return reflect.DeepEqual(x, y)
+}
+
+func TestMergePatch(t *testing.T) {
+	// The examples from appendix A of RFC 7396.
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch(%s, %s) returned error %v", tt.doc, tt.patch, err)
+			}
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("MergePatch(%s, %s) = %s; want %s", tt.doc, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	// Mostly the examples from appendix A of RFC 6902.
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{"Add an object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"Add an array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"Add to the end of an array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"Remove an object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"Remove an array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"Replace a value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{"Move a value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{"Move an array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{"Copy a value", `{"foo":{"bar":[1]}}`, `[{"op":"copy","from":"/foo/bar","path":"/baz"}]`, `{"foo":{"bar":[1]},"baz":[1]}`, nil},
+		{"Test a value", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"Test a null value", `{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`, nil},
+		{"Escaped pointer", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`, nil},
+		{"Add a nested member", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{"Test fails", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", ErrTestFailed},
+		{"Test fails after a change", `{"baz":"qux"}`, `[{"op":"replace","path":"/baz","value":"bar"},{"op":"test","path":"/baz","value":"qux"}]`, "", ErrTestFailed},
+		{"Add to a missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", ErrPathNotFound},
+		{"Remove a missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, "", ErrPathNotFound},
+		{"Array index out of range", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"baz"}]`, "", ErrPathNotFound},
+		{"Array index with a leading zero", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/01"}]`, "", ErrPathNotFound},
+		{"Missing value", `{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, "", ErrInvalidPatch},
+		{"Unknown op", `{"foo":"bar"}`, `[{"op":"frobnicate","path":"/foo"}]`, "", ErrInvalidPatch},
+		{"Move into a child", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, "", ErrInvalidPatch},
+		{"Not a patch", `{"foo":"bar"}`, `{"op":"remove","path":"/foo"}`, "", ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Apply(%s, %s) returned error %v; want %v", tt.doc, tt.patch, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Apply(%s, %s) returned error %v", tt.doc, tt.patch, err)
+			}
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("Apply(%s, %s) = %s; want %s", tt.doc, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+// Package openapi validates HTTP requests against the operations in an OpenAPI 3 document. It
+// supports the parts of the specification that the API's document uses: query parameters, JSON
+// request bodies, and schemas made of types, enums, ranges, lengths, patterns, required
+// properties, arrays, oneOf and references to component schemas and parameters.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+// Spec is a parsed OpenAPI 3 document.
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Parameters map[string]*Parameter `json:"parameters"`
+		Schemas    map[string]*Schema    `json:"schemas"`
+	} `json:"components"`
+
+	routes []*route
+}
+
+// Operation is a single API operation, i.e. a method on a path.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Security    []map[string][]string `json:"security"`
+	Permission  string                `json:"x-permission"`
+	Parameters  []*Parameter          `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+
+	spec *Spec
+}
+
+// Parameter is a path, query or header parameter of an operation, or a reference to one of the
+// component parameters.
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema, or a reference to one of the component schemas. A zero value
+// accepts any value.
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Nullable   bool               `json:"nullable"`
+	Enum       []string           `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	Pattern    string             `json:"pattern"`
+	MinItems   *int               `json:"minItems"`
+	MaxItems   *int               `json:"maxItems"`
+	Items      *Schema            `json:"items"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	OneOf      []*Schema          `json:"oneOf"`
+	// AdditionalProperties is either a boolean or a schema. Only a schema is used to validate
+	// the properties which aren't listed in Properties.
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+
+	additional *Schema
+	pattern    *regexp.Regexp
+}
+
+// route is a path template split into its segments, with the parameter segments left empty.
+type route struct {
+	method    string
+	segments  []string
+	literals  int
+	operation *Operation
+}
+
+// Load parses an OpenAPI 3 document.
+func Load(doc []byte) (*Spec, error) {
+	var spec Spec
+
+	err := json.Unmarshal(doc, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[*Schema]bool)
+
+	for _, schema := range spec.Components.Schemas {
+		err = schema.compile(seen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, parameter := range spec.Components.Parameters {
+		err = parameter.Schema.compile(seen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			operation.spec = &spec
+
+			for _, parameter := range operation.Parameters {
+				err = parameter.Schema.compile(seen)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if operation.RequestBody != nil {
+				for _, content := range operation.RequestBody.Content {
+					err = content.Schema.compile(seen)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			r := &route{method: strings.ToUpper(method), operation: operation}
+
+			for _, segment := range strings.Split(path, "/") {
+				if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+					segment = ""
+				} else {
+					r.literals++
+				}
+				r.segments = append(r.segments, segment)
+			}
+
+			spec.routes = append(spec.routes, r)
+		}
+	}
+
+	// Try the routes with the most literal segments first, so that a literal path such as
+	// /v1/movies/batch takes precedence over /v1/movies/{id}.
+	sort.SliceStable(spec.routes, func(i, j int) bool {
+		return spec.routes[i].literals > spec.routes[j].literals
+	})
+
+	return &spec, nil
+}
+
+// compile compiles the schema's pattern and parses its additionalProperties, along with those of
+// the schemas nested in it.
+func (s *Schema) compile(seen map[*Schema]bool) error {
+	if s == nil || seen[s] {
+		return nil
+	}
+	seen[s] = true
+
+	if s.Pattern != "" {
+		rx, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = rx
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(s.AdditionalProperties), []byte("{")) {
+		err := json.Unmarshal(s.AdditionalProperties, &s.additional)
+		if err != nil {
+			return err
+		}
+	}
+
+	nested := append([]*Schema{s.Items, s.additional}, s.OneOf...)
+	for _, property := range s.Properties {
+		nested = append(nested, property)
+	}
+
+	for _, schema := range nested {
+		err := schema.compile(seen)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Find returns the operation for a request method and URL path, or nil if there isn't one.
+func (spec *Spec) Find(method, path string) *Operation {
+	segments := strings.Split(path, "/")
+
+	for _, r := range spec.routes {
+		if r.method != method || len(r.segments) != len(segments) {
+			continue
+		}
+
+		match := true
+		for i, segment := range r.segments {
+			if segment != "" && segment != segments[i] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return r.operation
+		}
+	}
+
+	return nil
+}
+
+// schema follows a reference to a component schema.
+func (spec *Spec) schema(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// parameter follows a reference to a component parameter.
+func (spec *Spec) parameter(p *Parameter) *Parameter {
+	if p.Ref != "" {
+		return spec.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+	}
+	return p
+}
+
+// Secured reports whether the operation requires the client to authenticate.
+func (op *Operation) Secured() bool {
+	return len(op.Security) > 0
+}
+
+// BodySchema returns the schema of the operation's request body for a media type, or nil if the
+// operation doesn't accept a body of that type.
+func (op *Operation) BodySchema(mediaType string) *Schema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content[mediaType].Schema
+}
+
+// ValidateQuery checks the operation's query parameters in the query string, adding an error to
+// the validator for each invalid parameter. Parameters which are empty are treated as missing,
+// and parameters which aren't part of the operation are ignored.
+func (op *Operation) ValidateQuery(v *validator.Validator, qs url.Values) {
+	for _, parameter := range op.Parameters {
+		parameter = op.spec.parameter(parameter)
+		if parameter == nil || parameter.In != "query" {
+			continue
+		}
+
+		s := qs.Get(parameter.Name)
+		if s == "" {
+			v.Check(!parameter.Required, parameter.Name, "must be provided")
+			continue
+		}
+
+		var value interface{} = s
+
+		switch op.spec.schema(parameter.Schema).typ() {
+		case "integer", "number":
+			value = json.Number(s)
+		case "boolean":
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				v.AddError(parameter.Name, "must be a boolean value")
+				continue
+			}
+			value = b
+		}
+
+		op.spec.validate(v, parameter.Name, parameter.Schema, value)
+	}
+}
+
+// ValidateBody checks a JSON request body against the schema for the media type, adding an
+// error to the validator for each invalid field. It returns false without checking the body if
+// the body isn't a single valid JSON value, leaving it to the handler to report.
+func (op *Operation) ValidateBody(v *validator.Validator, mediaType string, body []byte) bool {
+	schema := op.BodySchema(mediaType)
+	if schema == nil {
+		return false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var value interface{}
+
+	err := dec.Decode(&value)
+	if err != nil || dec.More() {
+		return false
+	}
+
+	op.spec.validate(v, "", schema, value)
+
+	return true
+}
+
+// typ returns the type of a schema, or an empty string if it doesn't have one.
+func (s *Schema) typ() string {
+	if s == nil {
+		return ""
+	}
+	return s.Type
+}
+
+// validate checks a value decoded from JSON (with numbers as json.Number) against a schema,
+// adding an error to the validator under the key if it's invalid. The keys of nested values
+// are dotted paths, e.g. "operations[0].action".
+func (spec *Spec) validate(v *validator.Validator, key string, s *Schema, value interface{}) {
+	s = spec.schema(s)
+	if s == nil {
+		return
+	}
+
+	if value == nil {
+		v.Check(s.Nullable, errorKey(key), "must not be null")
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, option := range s.OneOf {
+			scratch := validator.New()
+			spec.validate(scratch, key, option, value)
+			if scratch.Valid() {
+				matches++
+			}
+		}
+
+		v.Check(matches == 1, errorKey(key), "is not in a valid format")
+	}
+
+	switch s.Type {
+	case "object":
+		spec.validateObject(v, key, s, value)
+	case "array":
+		spec.validateArray(v, key, s, value)
+	case "string":
+		validateString(v, key, s, value)
+	case "integer", "number":
+		validateNumber(v, key, s, value)
+	case "boolean":
+		_, ok := value.(bool)
+		v.Check(ok, errorKey(key), "must be a boolean value")
+	}
+}
+
+func (spec *Spec) validateObject(v *validator.Validator, key string, s *Schema, value interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		v.AddError(errorKey(key), "must be a JSON object")
+		return
+	}
+
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			v.AddError(join(key, name), "must be provided")
+		}
+	}
+
+	for name, property := range object {
+		schema, ok := s.Properties[name]
+		if !ok {
+			schema = s.additional
+		}
+
+		spec.validate(v, join(key, name), schema, property)
+	}
+}
+
+func (spec *Spec) validateArray(v *validator.Validator, key string, s *Schema, value interface{}) {
+	array, ok := value.([]interface{})
+	if !ok {
+		v.AddError(errorKey(key), "must be a JSON array")
+		return
+	}
+
+	if s.MinItems != nil {
+		v.Check(len(array) >= *s.MinItems, errorKey(key), fmt.Sprintf("must contain at least %d items", *s.MinItems))
+	}
+	if s.MaxItems != nil {
+		v.Check(len(array) <= *s.MaxItems, errorKey(key), fmt.Sprintf("must not contain more than %d items", *s.MaxItems))
+	}
+
+	for i, item := range array {
+		spec.validate(v, fmt.Sprintf("%s[%d]", key, i), s.Items, item)
+	}
+}
+
+func validateString(v *validator.Validator, key string, s *Schema, value interface{}) {
+	str, ok := value.(string)
+	if !ok {
+		v.AddError(errorKey(key), "must be a string")
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		v.Check(validator.In(str, s.Enum...), errorKey(key), fmt.Sprintf("must be one of %s", strings.Join(s.Enum, ", ")))
+	}
+
+	// Lengths are in characters rather than bytes, as in JSON Schema.
+	length := utf8.RuneCountInString(str)
+
+	if s.MinLength != nil {
+		v.Check(length >= *s.MinLength, errorKey(key), fmt.Sprintf("must be at least %d characters long", *s.MinLength))
+	}
+	if s.MaxLength != nil {
+		v.Check(length <= *s.MaxLength, errorKey(key), fmt.Sprintf("must not be more than %d characters long", *s.MaxLength))
+	}
+
+	if s.pattern != nil {
+		v.Check(s.pattern.MatchString(str), errorKey(key), fmt.Sprintf("must match the pattern %s", s.Pattern))
+	}
+
+	switch s.Format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, str)
+		v.Check(err == nil, errorKey(key), "must be an RFC 3339 timestamp")
+	case "email":
+		v.Check(validator.Matches(str, validator.EmailRX), errorKey(key), "must be a valid email address")
+	}
+}
+
+func validateNumber(v *validator.Validator, key string, s *Schema, value interface{}) {
+	n, ok := value.(json.Number)
+	if !ok {
+		v.AddError(errorKey(key), typeMessage(s.Type))
+		return
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		v.AddError(errorKey(key), typeMessage(s.Type))
+		return
+	}
+
+	if s.Type == "integer" {
+		if _, err := n.Int64(); err != nil {
+			v.AddError(errorKey(key), "must be an integer")
+			return
+		}
+	}
+
+	if s.Minimum != nil {
+		v.Check(f >= *s.Minimum, errorKey(key), fmt.Sprintf("must be at least %v", *s.Minimum))
+	}
+	if s.Maximum != nil {
+		v.Check(f <= *s.Maximum, errorKey(key), fmt.Sprintf("must not be more than %v", *s.Maximum))
+	}
+}
+
+// typeMessage returns the error message for a value which isn't a number of the numeric type.
+func typeMessage(typ string) string {
+	if typ == "number" {
+		return "must be a number"
+	}
+	return "must be an integer"
+}
+
+// join returns the key of a property of the value at key.
+func join(key, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + "." + name
+}
+
+// errorKey returns the validator key for the value at key. Errors in the body as a whole are
+// reported under "body".
+func errorKey(key string) string {
+	if key == "" {
+		return "body"
+	}
+	return key
+}
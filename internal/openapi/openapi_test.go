@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/DataDavD/snippetbox/greenlight/internal/validator"
+)
+
+const testSpec = `{
+	"openapi": "3.0.3",
+	"paths": {
+		"/v1/movies/{id}": {
+			"patch": {
+				"operationId": "updateMovie",
+				"security": [{"bearerAuth": []}],
+				"parameters": [{"$ref": "#/components/parameters/ID"}],
+				"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Movie"}}}}
+			}
+		},
+		"/v1/movies/batch": {
+			"post": {"operationId": "batchMovies"}
+		},
+		"/v1/movies": {
+			"get": {
+				"operationId": "listMovies",
+				"parameters": [
+					{"name": "page", "in": "query", "schema": {"type": "integer", "minimum": 1}},
+					{"name": "include_total", "in": "query", "schema": {"type": "boolean"}},
+					{"name": "release_status", "in": "query", "schema": {"type": "string", "enum": ["announced", "released"]}}
+				]
+			}
+		}
+	},
+	"components": {
+		"parameters": {
+			"ID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}
+		},
+		"schemas": {
+			"Movie": {
+				"type": "object",
+				"required": ["title"],
+				"properties": {
+					"title": {"type": "string", "maxLength": 5},
+					"runtime": {"oneOf": [{"type": "integer", "minimum": 1}, {"type": "string"}]},
+					"languages": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]{2}$"}},
+					"certifications": {"type": "object", "additionalProperties": {"type": "string"}},
+					"birth_year": {"type": "integer", "nullable": true}
+				}
+			}
+		}
+	}
+}`
+
+func TestFind(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"PATCH", "/v1/movies/1", "updateMovie"},
+		{"POST", "/v1/movies/batch", "batchMovies"},
+		{"GET", "/v1/movies", "listMovies"},
+		{"GET", "/v1/movies/1", ""},
+		{"PATCH", "/v1/movies/1/credits", ""},
+	}
+
+	for _, tt := range tests {
+		var got string
+		if op := spec.Find(tt.method, tt.path); op != nil {
+			got = op.OperationID
+		}
+
+		if got != tt.want {
+			t.Errorf("Find(%q, %q) = %q; want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := spec.Find("PATCH", "/v1/movies/1")
+
+	tests := []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{"Valid", `{"title": "Alien", "runtime": "1h 57m", "languages": ["en"], "birth_year": null}`, map[string]string{}},
+		{"Missing required property", `{}`, map[string]string{"title": "must be provided"}},
+		{"Characters rather than bytes", `{"title": "Amélie"}`, map[string]string{"title": "must not be more than 5 characters long"}},
+		{"Wrong type", `{"title": 1}`, map[string]string{"title": "must be a string"}},
+		{"Not null", `{"title": null}`, map[string]string{"title": "must not be null"}},
+		{"One of", `{"title": "Alien", "runtime": 0}`, map[string]string{"runtime": "is not in a valid format"}},
+		{"Array items", `{"title": "Alien", "languages": ["en", "EN"]}`, map[string]string{"languages[1]": "must match the pattern ^[a-z]{2}$"}},
+		{"Max items", `{"title": "Alien", "languages": ["en", "fr", "de"]}`, map[string]string{"languages": "must not contain more than 2 items"}},
+		{"Additional properties", `{"title": "Alien", "certifications": {"US": 15}}`, map[string]string{"certifications.US": "must be a string"}},
+		{"Not an object", `[]`, map[string]string{"body": "must be a JSON object"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+
+			if !op.ValidateBody(v, "application/json", []byte(tt.body)) {
+				t.Fatal("body was not validated")
+			}
+
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("got errors %v; want %v", v.Errors, tt.want)
+			}
+		})
+	}
+
+	if op.ValidateBody(validator.New(), "application/json", []byte(`{"title": `)) {
+		t.Error("invalid JSON was validated")
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := spec.Find("GET", "/v1/movies")
+
+	v := validator.New()
+	op.ValidateQuery(v, url.Values{
+		"page":           {"0"},
+		"include_total":  {"yes please"},
+		"release_status": {"rumoured"},
+		"unknown":        {"ignored"},
+	})
+
+	want := map[string]string{
+		"page":           "must be at least 1",
+		"include_total":  "must be a boolean value",
+		"release_status": "must be one of announced, released",
+	}
+
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("got errors %v; want %v", v.Errors, want)
+	}
+}
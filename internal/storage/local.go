@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local is a Storage backend which keeps objects as files under a root directory on the local
+// filesystem. It also implements http.Handler, so that the stored files can be served by the
+// application itself under baseURL.
+type Local struct {
+	root    string
+	baseURL string
+}
+
+// NewLocal returns a Local storage backend rooted at dir, creating the directory if it doesn't
+// already exist. URLs for stored objects are built by appending the key to baseURL.
+func NewLocal(dir, baseURL string) (*Local, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{
+		root:    dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// path returns the filesystem path for a key.
+func (l *Local) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the object to a temporary file first and then renames it into place, so that
+// readers never see a partially written file.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temporary file if anything goes wrong. Once it has been renamed this is a
+	// no-op.
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Delete removes the file for a key, along with any directories which are left empty.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Tidy up empty parent directories, stopping at the first one that isn't empty (or at the
+	// root).
+	for dir := filepath.Dir(name); dir != filepath.Clean(l.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// URL returns the URL for a key under baseURL.
+func (l *Local) URL(key string) string {
+	return l.baseURL + "/" + key
+}
+
+// ServeHTTP serves the stored file for the key in the request URL path. The handler should be
+// mounted with http.StripPrefix so that the path is relative to baseURL. Directory listings are
+// never served.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, err := l.path(strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := os.Stat(name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Stored objects never change once written (new uploads get new keys), so they can be
+	// cached indefinitely.
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, name)
+}
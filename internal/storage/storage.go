@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrInvalidKey is returned when a key is empty or would escape the storage root.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Storage is implemented by the backends that uploaded files can be stored in. Keys are
+// slash-separated relative paths such as "movies/1/abc/poster.jpg". The local filesystem
+// backend is the default; an object store backend only needs to implement this interface.
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Delete removes the object stored under key. Deleting a key that doesn't exist is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the URL that clients can fetch the object stored under key from.
+	URL(key string) string
+}
+
+// cleanKey checks that a key is a clean relative path which can't escape the storage root.
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+
+	return cleaned, nil
+}
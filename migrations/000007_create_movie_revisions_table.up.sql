@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS movie_revisions
+(
+	id         BIGSERIAL PRIMARY KEY,
+	movie_id   BIGINT                      NOT NULL,
+	version    INTEGER                     NOT NULL,
+	action     TEXT                        NOT NULL,
+	user_id    BIGINT                      REFERENCES users ON DELETE SET NULL,
+	changes    JSONB                       NOT NULL,
+	snapshot   JSONB                       NOT NULL,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE movie_revisions
+	ADD CONSTRAINT
+		movie_revisions_action_check CHECK (action IN ('insert', 'update', 'delete'));
+
+CREATE INDEX IF NOT EXISTS movie_revisions_movie_id_version_idx
+	ON movie_revisions (movie_id, version);
+
+-- Backfill an insert revision for every existing movie so that its current state can be
+-- restored later on.
+INSERT INTO movie_revisions (movie_id, version, action, changes, snapshot, created_at)
+SELECT id,
+			 version,
+			 'insert',
+			 JSONB_BUILD_OBJECT(
+				 'title', JSONB_BUILD_OBJECT('old', NULL, 'new', title),
+				 'year', JSONB_BUILD_OBJECT('old', NULL, 'new', year),
+				 'runtime', JSONB_BUILD_OBJECT('old', NULL, 'new', runtime || ' mins'),
+				 'genres', JSONB_BUILD_OBJECT('old', NULL, 'new', genres)
+				 ),
+			 JSONB_BUILD_OBJECT('title', title, 'year', year, 'runtime', runtime || ' mins', 'genres', genres),
+			 created_at
+FROM movies;
@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS ratings_maintain_movie_aggregates ON ratings;
+
+DROP FUNCTION IF EXISTS ratings_maintain_movie_aggregates();
+
+DROP TABLE IF EXISTS ratings;
+
+DROP INDEX IF EXISTS movies_average_rating_idx;
+
+DROP INDEX IF EXISTS movies_rating_count_idx;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS average_rating,
+	DROP COLUMN IF EXISTS rating_sum,
+	DROP COLUMN IF EXISTS rating_count;
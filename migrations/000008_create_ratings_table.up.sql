@@ -0,0 +1,60 @@
+CREATE TABLE IF NOT EXISTS ratings
+(
+	user_id    BIGINT                      NOT NULL REFERENCES users ON DELETE CASCADE,
+	movie_id   BIGINT                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+	rating     INTEGER                     NOT NULL,
+	review     TEXT                        NOT NULL DEFAULT '',
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	version    INTEGER                     NOT NULL DEFAULT 1,
+	PRIMARY KEY (user_id, movie_id)
+);
+
+ALTER TABLE ratings
+	ADD CONSTRAINT
+		ratings_rating_check CHECK (rating BETWEEN 1 AND 10);
+
+CREATE INDEX IF NOT EXISTS ratings_movie_id_idx
+	ON ratings (movie_id);
+
+-- Keep a running count and sum of the ratings for each movie, so that the average rating can
+-- be read (and sorted on) without aggregating the ratings table on every request.
+ALTER TABLE movies
+	ADD COLUMN IF NOT EXISTS rating_count   INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS rating_sum     BIGINT  NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS average_rating NUMERIC(4, 2) GENERATED ALWAYS AS (
+		CASE WHEN rating_count = 0 THEN 0 ELSE rating_sum::NUMERIC / rating_count END
+		) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_average_rating_idx
+	ON movies (average_rating);
+
+CREATE INDEX IF NOT EXISTS movies_rating_count_idx
+	ON movies (rating_count);
+
+CREATE OR REPLACE FUNCTION ratings_maintain_movie_aggregates() RETURNS TRIGGER AS
+$$
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		UPDATE movies
+		SET rating_count = rating_count - 1,
+				rating_sum   = rating_sum - OLD.rating
+		WHERE id = OLD.movie_id;
+	END IF;
+
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		UPDATE movies
+		SET rating_count = rating_count + 1,
+				rating_sum   = rating_sum + NEW.rating
+		WHERE id = NEW.movie_id;
+	END IF;
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ratings_maintain_movie_aggregates
+	AFTER INSERT OR UPDATE OF rating OR DELETE
+	ON ratings
+	FOR EACH ROW
+EXECUTE FUNCTION ratings_maintain_movie_aggregates();
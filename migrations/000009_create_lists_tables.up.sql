@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS lists
+(
+	id          BIGSERIAL PRIMARY KEY,
+	user_id     BIGINT                      NOT NULL REFERENCES users ON DELETE CASCADE,
+	name        TEXT                        NOT NULL,
+	description TEXT                        NOT NULL DEFAULT '',
+	visibility  TEXT                        NOT NULL DEFAULT 'private',
+	share_token TEXT UNIQUE                 NOT NULL,
+	created_at  TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	version     INTEGER                     NOT NULL DEFAULT 1
+);
+
+ALTER TABLE lists
+	ADD CONSTRAINT
+		lists_visibility_check CHECK (visibility IN ('private', 'unlisted', 'public'));
+
+CREATE INDEX IF NOT EXISTS lists_user_id_idx
+	ON lists (user_id);
+
+CREATE INDEX IF NOT EXISTS lists_visibility_idx
+	ON lists (visibility);
+
+-- Entries are removed from every list when the movie itself is deleted.
+CREATE TABLE IF NOT EXISTS list_entries
+(
+	list_id  BIGINT                      NOT NULL REFERENCES lists ON DELETE CASCADE,
+	movie_id BIGINT                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+	position INTEGER                     NOT NULL,
+	notes    TEXT                        NOT NULL DEFAULT '',
+	added_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (list_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS list_entries_list_id_position_idx
+	ON list_entries (list_id, position);
+
+CREATE INDEX IF NOT EXISTS list_entries_movie_id_idx
+	ON list_entries (movie_id);
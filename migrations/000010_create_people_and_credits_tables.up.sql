@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS people
+(
+	id         BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	name       TEXT                        NOT NULL,
+	birth_year INTEGER,
+	biography  TEXT                        NOT NULL DEFAULT '',
+	version    INTEGER                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx
+	ON people USING GIN (to_tsvector('simple', name));
+
+CREATE TABLE IF NOT EXISTS credits
+(
+	id            BIGSERIAL PRIMARY KEY,
+	movie_id      BIGINT  NOT NULL REFERENCES movies ON DELETE CASCADE,
+	person_id     BIGINT  NOT NULL REFERENCES people ON DELETE CASCADE,
+	role          TEXT    NOT NULL,
+	character     TEXT    NOT NULL DEFAULT '',
+	billing_order INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (movie_id, person_id, role, character)
+);
+
+ALTER TABLE credits
+	ADD CONSTRAINT
+		credits_role_check CHECK (role IN ('director', 'writer', 'producer', 'actor', 'composer',
+																			 'cinematographer', 'editor', 'crew'));
+
+CREATE INDEX IF NOT EXISTS credits_movie_id_billing_order_idx
+	ON credits (movie_id, billing_order);
+
+CREATE INDEX IF NOT EXISTS credits_person_id_idx
+	ON credits (person_id);
@@ -0,0 +1,90 @@
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE IF NOT EXISTS genres
+(
+	id         BIGSERIAL PRIMARY KEY,
+	name       CITEXT UNIQUE               NOT NULL,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS genre_aliases
+(
+	alias    CITEXT PRIMARY KEY,
+	genre_id BIGINT NOT NULL REFERENCES genres ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS genre_aliases_genre_id_idx
+	ON genre_aliases (genre_id);
+
+INSERT INTO genres (name)
+VALUES ('Action'),
+			 ('Adventure'),
+			 ('Animation'),
+			 ('Biography'),
+			 ('Comedy'),
+			 ('Crime'),
+			 ('Documentary'),
+			 ('Drama'),
+			 ('Family'),
+			 ('Fantasy'),
+			 ('History'),
+			 ('Horror'),
+			 ('Music'),
+			 ('Musical'),
+			 ('Mystery'),
+			 ('Romance'),
+			 ('Science Fiction'),
+			 ('Sport'),
+			 ('Thriller'),
+			 ('War'),
+			 ('Western')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO genre_aliases (alias, genre_id)
+SELECT aliases.alias, genres.id
+FROM (VALUES ('sci-fi', 'Science Fiction'),
+						 ('scifi', 'Science Fiction'),
+						 ('sf', 'Science Fiction'),
+						 ('animated', 'Animation'),
+						 ('biopic', 'Biography'),
+						 ('documentaries', 'Documentary'),
+						 ('doc', 'Documentary'),
+						 ('historical', 'History'),
+						 ('romantic', 'Romance'),
+						 ('sports', 'Sport'),
+						 ('suspense', 'Thriller')) AS aliases(alias, name)
+			 INNER JOIN genres ON genres.name = aliases.name::CITEXT
+ON CONFLICT DO NOTHING;
+
+-- Any genres already in use which don't match a canonical name or an alias become canonical
+-- genres in their own right, so that no existing data is lost.
+INSERT INTO genres (name)
+SELECT DISTINCT ON (LOWER(used.name)) used.name
+FROM movies
+			 CROSS JOIN LATERAL UNNEST(movies.genres) AS used(name)
+WHERE NOT EXISTS (SELECT 1 FROM genres WHERE genres.name = used.name::CITEXT)
+	AND NOT EXISTS (SELECT 1 FROM genre_aliases WHERE genre_aliases.alias = used.name::CITEXT)
+ORDER BY LOWER(used.name), used.name
+ON CONFLICT DO NOTHING;
+
+-- Rewrite the genres of existing movies to use the canonical names, dropping any duplicates that
+-- this creates but otherwise keeping the original order.
+UPDATE movies
+SET genres = normalised.genres
+FROM (SELECT deduplicated.movie_id, ARRAY_AGG(deduplicated.name ORDER BY deduplicated.ord) AS genres
+			FROM (SELECT DISTINCT ON (movies.id, canonical.name) movies.id AS movie_id,
+																													canonical.name::TEXT AS name,
+																													used.ord
+						FROM movies
+									 CROSS JOIN LATERAL UNNEST(movies.genres) WITH ORDINALITY AS used(name, ord)
+									 CROSS JOIN LATERAL (SELECT genres.name
+																			 FROM genres
+																			 WHERE genres.name = used.name::CITEXT
+																					OR genres.id = (SELECT genre_aliases.genre_id
+																													FROM genre_aliases
+																													WHERE genre_aliases.alias = used.name::CITEXT)
+																			 LIMIT 1) AS canonical
+						ORDER BY movies.id, canonical.name, used.ord) AS deduplicated
+			GROUP BY deduplicated.movie_id) AS normalised
+WHERE movies.id = normalised.movie_id
+	AND movies.genres <> normalised.genres;
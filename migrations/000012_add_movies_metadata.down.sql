@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS movies_languages_idx;
+
+DROP INDEX IF EXISTS movies_certifications_idx;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS synopsis,
+	DROP COLUMN IF EXISTS original_title,
+	DROP COLUMN IF EXISTS languages,
+	DROP COLUMN IF EXISTS certifications,
+	DROP COLUMN IF EXISTS imdb_id,
+	DROP COLUMN IF EXISTS tmdb_id;
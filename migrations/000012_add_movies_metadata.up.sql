@@ -0,0 +1,21 @@
+ALTER TABLE movies
+	ADD COLUMN IF NOT EXISTS synopsis       TEXT   NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS original_title TEXT   NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS languages      TEXT[] NOT NULL DEFAULT '{}',
+	ADD COLUMN IF NOT EXISTS certifications JSONB  NOT NULL DEFAULT '{}',
+	ADD COLUMN IF NOT EXISTS imdb_id        TEXT UNIQUE,
+	ADD COLUMN IF NOT EXISTS tmdb_id        BIGINT UNIQUE;
+
+ALTER TABLE movies
+	ADD CONSTRAINT
+		movies_imdb_id_check CHECK (imdb_id ~ '^tt[0-9]{7,10}$');
+
+ALTER TABLE movies
+	ADD CONSTRAINT
+		movies_tmdb_id_check CHECK (tmdb_id > 0);
+
+CREATE INDEX IF NOT EXISTS movies_languages_idx
+	ON movies USING GIN (languages);
+
+CREATE INDEX IF NOT EXISTS movies_certifications_idx
+	ON movies USING GIN (certifications);
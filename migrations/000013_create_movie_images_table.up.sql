@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS movie_images
+(
+	id           BIGSERIAL PRIMARY KEY,
+	created_at   TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	movie_id     BIGINT                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+	kind         TEXT                        NOT NULL,
+	content_type TEXT                        NOT NULL,
+	width        INTEGER                     NOT NULL,
+	height       INTEGER                     NOT NULL,
+	variants     JSONB                       NOT NULL DEFAULT '{}'
+);
+
+ALTER TABLE movie_images
+	ADD CONSTRAINT
+		movie_images_kind_check CHECK (kind IN ('poster', 'backdrop', 'still'));
+
+CREATE INDEX IF NOT EXISTS movie_images_movie_id_idx
+	ON movie_images (movie_id);
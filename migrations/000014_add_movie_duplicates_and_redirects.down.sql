@@ -0,0 +1,10 @@
+DELETE FROM permissions
+WHERE code = 'movies:admin';
+
+DROP TABLE IF EXISTS movie_redirects;
+
+DROP INDEX IF EXISTS movies_normalised_title_trgm_idx;
+
+DROP INDEX IF EXISTS movies_normalised_title_year_idx;
+
+DROP FUNCTION IF EXISTS normalise_title(TEXT);
@@ -0,0 +1,35 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- normalise_title reduces a title to a form suitable for duplicate detection: lower case, with
+-- any leading article removed and runs of punctuation and whitespace collapsed to a single
+-- space. So "The Matrix" and "matrix!" both become "matrix".
+CREATE OR REPLACE FUNCTION normalise_title(title TEXT) RETURNS TEXT
+	LANGUAGE SQL
+	IMMUTABLE
+	PARALLEL SAFE
+AS
+$$
+SELECT btrim(regexp_replace(regexp_replace(lower(title), '^(the|a|an)\s+', ''), '[^[:alnum:]]+', ' ', 'g'))
+$$;
+
+CREATE INDEX IF NOT EXISTS movies_normalised_title_year_idx
+	ON movies (normalise_title(title), year);
+
+CREATE INDEX IF NOT EXISTS movies_normalised_title_trgm_idx
+	ON movies USING GIN (normalise_title(title) gin_trgm_ops);
+
+-- When a movie is merged into another, lookups of the old ID are redirected to the movie that it
+-- was merged into.
+CREATE TABLE IF NOT EXISTS movie_redirects
+(
+	old_id     BIGINT PRIMARY KEY,
+	movie_id   BIGINT                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+	user_id    BIGINT                      REFERENCES users ON DELETE SET NULL,
+	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS movie_redirects_movie_id_idx
+	ON movie_redirects (movie_id);
+
+INSERT INTO permissions (code)
+VALUES ('movies:admin');
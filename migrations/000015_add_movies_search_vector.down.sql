@@ -0,0 +1,11 @@
+CREATE INDEX IF NOT EXISTS movies_title_idx
+	ON movies USING GIN (to_tsvector('simple', title));
+
+DROP INDEX IF EXISTS movies_search_vector_idx;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS search_vector,
+	DROP COLUMN IF EXISTS search_config;
+
+DROP FUNCTION IF EXISTS movie_genres_text(TEXT[]);
+DROP FUNCTION IF EXISTS movie_search_config(TEXT[]);
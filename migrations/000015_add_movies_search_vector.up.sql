@@ -0,0 +1,58 @@
+-- movie_search_config picks the text search configuration for a movie from its first spoken
+-- language, so that words are stemmed according to the language they are written in. Movies
+-- without a language, or in a language that PostgreSQL has no configuration for, use the
+-- 'simple' configuration which doesn't stem at all.
+--
+-- Any change to the configurations returned here must also be made to searchConfigs in
+-- internal/data/search.go.
+CREATE OR REPLACE FUNCTION movie_search_config(languages TEXT[]) RETURNS REGCONFIG
+	LANGUAGE SQL
+	IMMUTABLE
+	PARALLEL SAFE
+AS
+$$
+SELECT CASE languages[1]
+		WHEN 'da' THEN 'danish'::REGCONFIG
+		WHEN 'de' THEN 'german'::REGCONFIG
+		WHEN 'en' THEN 'english'::REGCONFIG
+		WHEN 'es' THEN 'spanish'::REGCONFIG
+		WHEN 'fi' THEN 'finnish'::REGCONFIG
+		WHEN 'fr' THEN 'french'::REGCONFIG
+		WHEN 'hu' THEN 'hungarian'::REGCONFIG
+		WHEN 'it' THEN 'italian'::REGCONFIG
+		WHEN 'nl' THEN 'dutch'::REGCONFIG
+		WHEN 'no' THEN 'norwegian'::REGCONFIG
+		WHEN 'pt' THEN 'portuguese'::REGCONFIG
+		WHEN 'ru' THEN 'russian'::REGCONFIG
+		WHEN 'sv' THEN 'swedish'::REGCONFIG
+		WHEN 'tr' THEN 'turkish'::REGCONFIG
+		ELSE 'simple'::REGCONFIG
+	END
+$$;
+
+-- movie_genres_text joins a movie's genres into a single string for the search vector.
+-- array_to_string is only STABLE, which isn't allowed in a generated column, but joining an array
+-- of text with a space doesn't depend on any settings, so it's safe to declare this IMMUTABLE.
+CREATE OR REPLACE FUNCTION movie_genres_text(genres TEXT[]) RETURNS TEXT
+	LANGUAGE SQL
+	IMMUTABLE
+	PARALLEL SAFE
+AS
+$$
+SELECT array_to_string(genres, ' ')
+$$;
+
+-- The search vector weights matches in the titles highest, then genres, then the synopsis.
+ALTER TABLE movies
+	ADD COLUMN IF NOT EXISTS search_config REGCONFIG GENERATED ALWAYS AS (movie_search_config(languages)) STORED,
+	ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+			setweight(to_tsvector(movie_search_config(languages), title), 'A') ||
+			setweight(to_tsvector(movie_search_config(languages), original_title), 'A') ||
+			setweight(to_tsvector('simple', movie_genres_text(genres)), 'B') ||
+			setweight(to_tsvector(movie_search_config(languages), synopsis), 'C')
+		) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx
+	ON movies USING GIN (search_vector);
+
+DROP INDEX IF EXISTS movies_title_idx;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_title_lower_trgm_idx;
+
+DROP INDEX IF EXISTS movies_title_lower_pattern_idx;
@@ -0,0 +1,7 @@
+-- Support autocompletion of titles: the pattern index serves prefix matches (LIKE 'abc%'), and
+-- the trigram index serves matches anywhere in the title (LIKE '%abc%').
+CREATE INDEX IF NOT EXISTS movies_title_lower_pattern_idx
+	ON movies (lower(title) text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS movies_title_lower_trgm_idx
+	ON movies USING GIN (lower(title) gin_trgm_ops);
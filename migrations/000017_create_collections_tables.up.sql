@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS collections
+(
+	id          BIGSERIAL PRIMARY KEY,
+	created_at  TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	name        TEXT                        NOT NULL,
+	description TEXT                        NOT NULL DEFAULT '',
+	version     INTEGER                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS collections_name_idx
+	ON collections USING GIN (to_tsvector('simple', name));
+
+-- Movies are removed from every collection when the movie itself is deleted. Positions run from 1
+-- in the order of the collection, such as release order for a franchise.
+CREATE TABLE IF NOT EXISTS collection_movies
+(
+	collection_id BIGINT  NOT NULL REFERENCES collections ON DELETE CASCADE,
+	movie_id      BIGINT  NOT NULL REFERENCES movies ON DELETE CASCADE,
+	position      INTEGER NOT NULL,
+	PRIMARY KEY (collection_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS collection_movies_collection_id_position_idx
+	ON collection_movies (collection_id, position);
+
+CREATE INDEX IF NOT EXISTS collection_movies_movie_id_idx
+	ON collection_movies (movie_id);
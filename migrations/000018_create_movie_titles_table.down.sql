@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS movies_search_vector_idx;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE movies
+	ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+			setweight(to_tsvector(movie_search_config(languages), title), 'A') ||
+			setweight(to_tsvector(movie_search_config(languages), original_title), 'A') ||
+			setweight(to_tsvector('simple', movie_genres_text(genres)), 'B') ||
+			setweight(to_tsvector(movie_search_config(languages), synopsis), 'C')
+		) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx
+	ON movies USING GIN (search_vector);
+
+DROP TABLE IF EXISTS movie_titles;
+
+DROP FUNCTION IF EXISTS movie_titles_maintain_alternate_titles();
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS alternate_titles;
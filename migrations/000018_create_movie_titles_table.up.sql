@@ -0,0 +1,56 @@
+-- Alternate titles for a movie in other languages, optionally for a specific region (e.g. the
+-- French-Canadian title has the language 'fr' and the region 'CA').
+CREATE TABLE IF NOT EXISTS movie_titles
+(
+	id       BIGSERIAL PRIMARY KEY,
+	movie_id BIGINT NOT NULL REFERENCES movies ON DELETE CASCADE,
+	language TEXT   NOT NULL,
+	region   TEXT   NOT NULL DEFAULT '',
+	title    TEXT   NOT NULL,
+	UNIQUE (movie_id, language, region)
+);
+
+-- Keep a copy of each movie's alternate titles on the movie itself, so that they can be part of
+-- its search vector.
+ALTER TABLE movies
+	ADD COLUMN IF NOT EXISTS alternate_titles TEXT NOT NULL DEFAULT '';
+
+CREATE OR REPLACE FUNCTION movie_titles_maintain_alternate_titles() RETURNS TRIGGER AS
+$$
+BEGIN
+	UPDATE movies
+	SET alternate_titles = COALESCE((SELECT string_agg(movie_titles.title, ' ' ORDER BY movie_titles.id)
+																	 FROM movie_titles
+																	 WHERE movie_titles.movie_id = movies.id), '')
+	WHERE id IN (OLD.movie_id, NEW.movie_id);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The same function handles every operation, since OLD is NULL for inserts and NEW is NULL for
+-- deletes.
+CREATE TRIGGER movie_titles_maintain_alternate_titles
+	AFTER INSERT OR UPDATE OR DELETE
+	ON movie_titles
+	FOR EACH ROW
+EXECUTE FUNCTION movie_titles_maintain_alternate_titles();
+
+-- Rebuild the search vector to include the alternate titles with the same weight as the other
+-- titles. They're in many languages, so they use the 'simple' configuration.
+DROP INDEX IF EXISTS movies_search_vector_idx;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE movies
+	ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+			setweight(to_tsvector(movie_search_config(languages), title), 'A') ||
+			setweight(to_tsvector(movie_search_config(languages), original_title), 'A') ||
+			setweight(to_tsvector('simple', alternate_titles), 'A') ||
+			setweight(to_tsvector('simple', movie_genres_text(genres)), 'B') ||
+			setweight(to_tsvector(movie_search_config(languages), synopsis), 'C')
+		) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx
+	ON movies USING GIN (search_vector);
@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS movies_release_status_idx;
+
+ALTER TABLE movies
+	DROP CONSTRAINT IF EXISTS movies_year_check;
+
+ALTER TABLE movies
+	DROP CONSTRAINT IF EXISTS movies_release_status_check;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS release_dates;
+
+ALTER TABLE movies
+	DROP COLUMN IF EXISTS release_status;
+
+-- Note that this fails if there are still movies with a year in the future.
+ALTER TABLE movies
+	ADD CONSTRAINT
+		movies_year_check CHECK (year BETWEEN 1888 AND DATE_PART('year', NOW()));
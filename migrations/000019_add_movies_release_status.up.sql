@@ -0,0 +1,25 @@
+-- The release status of a movie. Announced and in-production movies can have a year in the
+-- future, which is the year they are expected to be released.
+ALTER TABLE movies
+	ADD COLUMN IF NOT EXISTS release_status TEXT NOT NULL DEFAULT 'released';
+
+-- Maps ISO 3166-1 alpha-2 country codes to the date the movie is (or was) released there,
+-- e.g. {"US": "2024-03-01"}.
+ALTER TABLE movies
+	ADD COLUMN IF NOT EXISTS release_dates JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE movies
+	ADD CONSTRAINT
+		movies_release_status_check CHECK (release_status IN ('announced', 'in_production', 'released'));
+
+ALTER TABLE movies
+	DROP CONSTRAINT IF EXISTS movies_year_check;
+
+ALTER TABLE movies
+	ADD CONSTRAINT
+		movies_year_check CHECK (year >= 1888 AND
+														 (year <= DATE_PART('year', NOW()) OR release_status <> 'released'));
+
+CREATE INDEX IF NOT EXISTS movies_release_status_idx
+	ON movies (release_status)
+	WHERE release_status <> 'released';